@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egorsmkv/gemma-cli/pkg/schema"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// isBatchInput reports whether the given -input/-input-dir combination
+// should run through the batch pipeline instead of the single-file flow:
+// a directory of per-record files, or a .jsonl file of records.
+func isBatchInput(inputFile, inputDir string) bool {
+	return inputDir != "" || strings.HasSuffix(strings.ToLower(inputFile), ".jsonl")
+}
+
+// batchRecord is one unit of work: an identifier (used for checkpointing
+// and to label results) and the input text that gets embedded into the
+// prompt the same way a single -input file's contents are in run.
+type batchRecord struct {
+	ID    string
+	Input string
+}
+
+// batchResult is a single line of the -output JSONL file.
+type batchResult struct {
+	ID     string `json:"id"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runBatch drives the worker pool described by config over every record in
+// -input (a .jsonl file) or -input-dir (a directory of per-record files),
+// writing one JSON result per record to -output as JSONL.
+func runBatch(config Config) error {
+	if config.OutputFile == "" {
+		return fmt.Errorf("-output is required in batch mode")
+	}
+
+	promptContent, err := os.ReadFile(config.PromptFile)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	var jsonSchema map[string]any
+	if config.SchemaFile != "" {
+		schemaContent, err := os.ReadFile(config.SchemaFile)
+		if err != nil {
+			return fmt.Errorf("failed to read schema file: %w", err)
+		}
+		if err := json.Unmarshal(schemaContent, &jsonSchema); err != nil {
+			return fmt.Errorf("failed to parse schema file: %w", err)
+		}
+	} else {
+		jsonSchema = DefaultSchema
+	}
+
+	records, err := loadBatchRecords(config.InputFile, config.InputDir)
+	if err != nil {
+		return err
+	}
+
+	completed, err := loadCheckpoint(config.CheckpointFile)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.APIKey))
+	if err != nil {
+		return fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+	defer client.Close()
+
+	model := client.GenerativeModel(config.Model)
+	model.ResponseMIMEType = "application/json"
+	genaiSchema, err := schema.Compile(jsonSchema)
+	if err != nil {
+		return fmt.Errorf("failed to convert schema: %w", err)
+	}
+	model.ResponseSchema = genaiSchema
+
+	var limiter *rateLimiter
+	if config.RPM > 0 {
+		limiter = newRateLimiter(config.RPM)
+		defer limiter.Stop()
+	}
+
+	outFile, err := os.OpenFile(config.OutputFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer outFile.Close()
+
+	checkpointFile, err := os.OpenFile(config.CheckpointFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	defer checkpointFile.Close()
+
+	// writeResult appends res to -output and, only on success, records its ID
+	// in the checkpoint file — a failed record must stay eligible for retry
+	// on the next resumed run, not be skipped as if it had completed.
+	writeResult := func(res batchResult) error {
+		line, err := json.Marshal(res)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result for record %q: %w", res.ID, err)
+		}
+		if _, err := outFile.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write result for record %q: %w", res.ID, err)
+		}
+		if res.Error == "" {
+			if _, err := fmt.Fprintln(checkpointFile, res.ID); err != nil {
+				return fmt.Errorf("failed to checkpoint record %q: %w", res.ID, err)
+			}
+		}
+		return nil
+	}
+
+	// toProcess holds the records this run actually needs to make calls for
+	// (skipping anything already checkpointed), in their original input order.
+	var toProcess []batchRecord
+	for _, rec := range records {
+		if !completed[rec.ID] {
+			toProcess = append(toProcess, rec)
+		}
+	}
+
+	pending := make(chan indexedRecord)
+	go func() {
+		defer close(pending)
+		for i, rec := range toProcess {
+			pending <- indexedRecord{index: i, rec: rec}
+		}
+	}()
+
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	done := make(chan indexedResult)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ir := range pending {
+				resultText, err := processRecord(ctx, model, string(promptContent), ir.rec, limiter, config.MaxRetries, config.RetryBase, config.Stream)
+
+				res := batchResult{ID: ir.rec.ID}
+				if err != nil {
+					res.Error = err.Error()
+					slog.Error("Record failed", "id", ir.rec.ID, "error", err)
+				} else {
+					var parsed any
+					if err := json.Unmarshal([]byte(resultText), &parsed); err != nil {
+						res.Error = fmt.Sprintf("failed to parse response as JSON: %v", err)
+					} else {
+						res.Result = parsed
+					}
+				}
+
+				done <- indexedResult{index: ir.index, res: res}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	return reorderResults(done, writeResult)
+}
+
+// indexedRecord pairs a batchRecord with its position in the input order, so
+// workers can process records concurrently while still reporting where each
+// result belongs.
+type indexedRecord struct {
+	index int
+	rec   batchRecord
+}
+
+// indexedResult pairs a batchResult with the input index it was produced
+// for.
+type indexedResult struct {
+	index int
+	res   batchResult
+}
+
+// reorderResults drains done and calls writeResult for each result in input
+// order: workers finish out of order under concurrency, so results are
+// buffered by index and only the contiguous prefix starting at the next
+// expected index is flushed. Returns the first error writeResult produced,
+// if any, after draining the rest of done.
+func reorderResults(done <-chan indexedResult, writeResult func(batchResult) error) error {
+	var firstErr error
+	buffered := make(map[int]batchResult)
+	next := 0
+	for ir := range done {
+		buffered[ir.index] = ir.res
+		for {
+			res, ok := buffered[next]
+			if !ok {
+				break
+			}
+			delete(buffered, next)
+			next++
+			if err := writeResult(res); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// processRecord issues one Gemini call for rec, retrying with exponential
+// backoff and jitter on retryable errors (429/5xx) up to maxRetries times.
+func processRecord(ctx context.Context, model *genai.GenerativeModel, promptContent string, rec batchRecord, limiter *rateLimiter, maxRetries int, retryBase time.Duration, stream bool) (string, error) {
+	prompt := genai.Text(fmt.Sprintf("%s\n\nInput:\n%s", promptContent, rec.Input))
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if limiter != nil {
+			limiter.Wait(ctx)
+		}
+
+		text, err := generateOnce(ctx, model, prompt, rec.ID, stream)
+		if err == nil {
+			return text, nil
+		}
+		lastErr = err
+
+		if attempt == maxRetries || !isRetryableError(err) {
+			break
+		}
+		time.Sleep(backoff(attempt, retryBase))
+	}
+
+	return "", fmt.Errorf("record %q: %w", rec.ID, lastErr)
+}
+
+// generateOnce makes a single model call, streaming it (and flushing each
+// chunk to stderr for visibility into long generations) when stream is
+// true, and returns the concatenated response text.
+func generateOnce(ctx context.Context, model *genai.GenerativeModel, prompt genai.Part, id string, stream bool) (string, error) {
+	if !stream {
+		resp, err := model.GenerateContent(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		return textFromResponse(resp)
+	}
+
+	var text strings.Builder
+	iter := model.GenerateContentStream(ctx, prompt)
+	for {
+		resp, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		chunk, err := textFromResponse(resp)
+		if err != nil {
+			continue
+		}
+		text.WriteString(chunk)
+		fmt.Fprintf(os.Stderr, "[%s] %s", id, chunk)
+	}
+	return text.String(), nil
+}
+
+func textFromResponse(resp *genai.GenerateContentResponse) (string, error) {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", fmt.Errorf("no response candidates received")
+	}
+	var b strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if txt, ok := part.(genai.Text); ok {
+			b.WriteString(string(txt))
+		}
+	}
+	return b.String(), nil
+}
+
+// isRetryableError reports whether err looks like a transient 429/5xx
+// response worth retrying, based on the status text the genai/googleapi
+// client surfaces.
+func isRetryableError(err error) bool {
+	msg := err.Error()
+	for _, marker := range []string{"429", "RESOURCE_EXHAUSTED", "500", "502", "503", "504", "UNAVAILABLE"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns an exponential delay for the given (zero-based) retry
+// attempt, with up to 50% jitter to avoid a thundering herd.
+func backoff(attempt int, base time.Duration) time.Duration {
+	d := base << attempt
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d + jitter
+}
+
+// rateLimiter is a simple token-bucket limiter: the bucket holds rpm tokens,
+// starts full and gains one token every minute/rpm, so the sustained rate
+// is rpm requests per minute after an initial burst of up to rpm requests.
+type rateLimiter struct {
+	tokens chan struct{}
+	done   chan struct{}
+}
+
+func newRateLimiter(rpm int) *rateLimiter {
+	rl := &rateLimiter{
+		tokens: make(chan struct{}, rpm),
+		done:   make(chan struct{}),
+	}
+	for i := 0; i < rpm; i++ {
+		rl.tokens <- struct{}{}
+	}
+
+	interval := time.Minute / time.Duration(rpm)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case rl.tokens <- struct{}{}:
+				default:
+				}
+			case <-rl.done:
+				return
+			}
+		}
+	}()
+	return rl
+}
+
+func (rl *rateLimiter) Wait(ctx context.Context) {
+	select {
+	case <-rl.tokens:
+	case <-ctx.Done():
+	}
+}
+
+func (rl *rateLimiter) Stop() {
+	close(rl.done)
+}
+
+// loadBatchRecords reads records either from every file in inputDir (one
+// record per file, ID = filename) or from a .jsonl file (one record per
+// line; ID comes from the line's "id" field if present, else its line
+// index).
+func loadBatchRecords(inputFile, inputDir string) ([]batchRecord, error) {
+	if inputDir != "" {
+		entries, err := os.ReadDir(inputDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input directory: %w", err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+
+		records := make([]batchRecord, 0, len(names))
+		for _, name := range names {
+			content, err := os.ReadFile(filepath.Join(inputDir, name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read input file %q: %w", name, err)
+			}
+			records = append(records, batchRecord{ID: name, Input: string(content)})
+		}
+		return records, nil
+	}
+
+	f, err := os.Open(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	var records []batchRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for lineNum := 0; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		id := strconv.Itoa(lineNum)
+		var decoded map[string]any
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", lineNum, err)
+		}
+		if rawID, ok := decoded["id"]; ok {
+			id = fmt.Sprint(rawID)
+		}
+
+		records = append(records, batchRecord{ID: id, Input: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+	return records, nil
+}
+
+// loadCheckpoint reads the set of record IDs already completed by a prior
+// run of the same -output/-checkpoint pair, so a re-run skips them.
+func loadCheckpoint(path string) (map[string]bool, error) {
+	completed := make(map[string]bool)
+	if path == "" {
+		return completed, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id != "" {
+			completed[id] = true
+		}
+	}
+	return completed, scanner.Err()
+}
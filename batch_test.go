@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIsBatchInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputFile string
+		inputDir  string
+		want      bool
+	}{
+		{name: "input dir is always batch", inputFile: "", inputDir: "records/", want: true},
+		{name: "jsonl file is batch", inputFile: "records.jsonl", want: true},
+		{name: "jsonl file is case-insensitive", inputFile: "records.JSONL", want: true},
+		{name: "plain text file is not batch", inputFile: "input.txt", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBatchInput(tt.inputFile, tt.inputDir); got != tt.want {
+				t.Fatalf("isBatchInput(%q, %q) = %v, want %v", tt.inputFile, tt.inputDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderResults_RestoresInputOrder(t *testing.T) {
+	done := make(chan indexedResult)
+	go func() {
+		defer close(done)
+		// Deliver out of order: 2, 0, 1.
+		done <- indexedResult{index: 2, res: batchResult{ID: "c"}}
+		done <- indexedResult{index: 0, res: batchResult{ID: "a"}}
+		done <- indexedResult{index: 1, res: batchResult{ID: "b"}}
+	}()
+
+	var written []string
+	err := reorderResults(done, func(res batchResult) error {
+		written = append(written, res.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reorderResults() error: %v", err)
+	}
+	want := []string{"a", "b", "c"}
+	if len(written) != len(want) {
+		t.Fatalf("written = %v, want %v", written, want)
+	}
+	for i, id := range want {
+		if written[i] != id {
+			t.Fatalf("written = %v, want %v", written, want)
+		}
+	}
+}
+
+func TestReorderResults_ReturnsFirstWriteError(t *testing.T) {
+	done := make(chan indexedResult, 2)
+	done <- indexedResult{index: 0, res: batchResult{ID: "a"}}
+	done <- indexedResult{index: 1, res: batchResult{ID: "b"}}
+	close(done)
+
+	wantErr := errors.New("disk full")
+	err := reorderResults(done, func(res batchResult) error {
+		if res.ID == "a" {
+			return wantErr
+		}
+		return nil
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("reorderResults() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{msg: "googleapi: Error 429: Resource exhausted, RESOURCE_EXHAUSTED", want: true},
+		{msg: "googleapi: Error 503: Service unavailable, UNAVAILABLE", want: true},
+		{msg: "googleapi: Error 500: Internal error", want: true},
+		{msg: "googleapi: Error 400: Invalid argument", want: false},
+		{msg: "context deadline exceeded", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := isRetryableError(errors.New(tt.msg)); got != tt.want {
+				t.Fatalf("isRetryableError(%q) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff_GrowsExponentiallyWithJitter(t *testing.T) {
+	base := 10 * time.Millisecond
+	for attempt := 0; attempt < 4; attempt++ {
+		d := backoff(attempt, base)
+		min := base << attempt
+		max := min + min/2
+		if d < min || d > max {
+			t.Fatalf("backoff(%d, %v) = %v, want in [%v, %v]", attempt, base, d, min, max)
+		}
+	}
+}
+
+func TestLoadBatchRecords_FromDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.txt", "a.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("content-"+name), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	records, err := loadBatchRecords("", dir)
+	if err != nil {
+		t.Fatalf("loadBatchRecords() error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) = %d, want 2", len(records))
+	}
+	// Entries are sorted by filename regardless of directory listing order.
+	if records[0].ID != "a.txt" || records[1].ID != "b.txt" {
+		t.Fatalf("records = %+v, want a.txt then b.txt", records)
+	}
+}
+
+func TestLoadBatchRecords_FromJSONL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "records.jsonl")
+	content := "{\"id\":\"rec-1\",\"text\":\"hi\"}\n\n{\"text\":\"no id\"}\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	records, err := loadBatchRecords(path, "")
+	if err != nil {
+		t.Fatalf("loadBatchRecords() error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) = %d, want 2", len(records))
+	}
+	if records[0].ID != "rec-1" {
+		t.Fatalf("records[0].ID = %q, want %q (explicit id field)", records[0].ID, "rec-1")
+	}
+	if records[1].ID != "2" {
+		t.Fatalf("records[1].ID = %q, want %q (falls back to line index)", records[1].ID, "2")
+	}
+}
+
+func TestLoadBatchRecords_InvalidJSONLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "records.jsonl")
+	if err := os.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := loadBatchRecords(path, ""); err == nil {
+		t.Fatalf("loadBatchRecords() error = nil, want error for invalid JSON line")
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	t.Run("missing file yields empty set", func(t *testing.T) {
+		completed, err := loadCheckpoint(filepath.Join(t.TempDir(), "missing.txt"))
+		if err != nil {
+			t.Fatalf("loadCheckpoint() error: %v", err)
+		}
+		if len(completed) != 0 {
+			t.Fatalf("completed = %v, want empty", completed)
+		}
+	})
+
+	t.Run("empty path yields empty set", func(t *testing.T) {
+		completed, err := loadCheckpoint("")
+		if err != nil {
+			t.Fatalf("loadCheckpoint() error: %v", err)
+		}
+		if len(completed) != 0 {
+			t.Fatalf("completed = %v, want empty", completed)
+		}
+	})
+
+	t.Run("reads completed IDs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.txt")
+		if err := os.WriteFile(path, []byte("a\nb\n\nc\n"), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		completed, err := loadCheckpoint(path)
+		if err != nil {
+			t.Fatalf("loadCheckpoint() error: %v", err)
+		}
+		var ids []string
+		for id := range completed {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		want := []string{"a", "b", "c"}
+		if len(ids) != len(want) {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+		for i, id := range want {
+			if ids[i] != id {
+				t.Fatalf("ids = %v, want %v", ids, want)
+			}
+		}
+	})
+}
+
+func TestRateLimiter_CapsTokensAtRPM(t *testing.T) {
+	rl := newRateLimiter(2)
+	defer rl.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rl.Wait(ctx)
+	rl.Wait(ctx)
+
+	// The bucket starts full at rpm=2 and refills every 30s, so a third Wait
+	// within this short window should block until ctx is done rather than
+	// return immediately.
+	start := time.Now()
+	rl.Wait(ctx)
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("third Wait() returned after %v, want it to block for the full timeout", elapsed)
+	}
+}
@@ -0,0 +1,332 @@
+// Command schemagen reads a JSON schema file and emits a Go package with
+// typed structs, a Generate helper that drives a genai.GenerativeModel with
+// that schema, and basic validation methods. It turns gemma-cli's
+// stringly-typed -schema pipeline into a compile-checked one for repeat use
+// cases.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+	"strings"
+)
+
+func main() {
+	var (
+		schemaFile  = flag.String("schema", "", "Path to JSON schema file (required)")
+		packageName = flag.String("package", "schemagen", "Go package name for the generated file")
+		outputFile  = flag.String("output", "", "Output file path (default: stdout)")
+	)
+	flag.Parse()
+
+	if *schemaFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s -schema=<schema.json> [-package=<name>] [-output=<file.go>]\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	if err := run(*schemaFile, *packageName, *outputFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(schemaFile, packageName, outputFile string) error {
+	raw, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("failed to read schema file: %w", err)
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return fmt.Errorf("failed to parse schema file: %w", err)
+	}
+
+	gen := newGenerator(root)
+	rootType, err := gen.typeFor(root, "Root", "#")
+	if err != nil {
+		return fmt.Errorf("failed to walk schema: %w", err)
+	}
+
+	source, err := gen.render(packageName, rootType, string(raw))
+	if err != nil {
+		return fmt.Errorf("failed to render generated code: %w", err)
+	}
+
+	formatted, err := format.Source(source)
+	if err != nil {
+		return fmt.Errorf("failed to gofmt generated code: %w", err)
+	}
+
+	if outputFile != "" {
+		if err := os.WriteFile(outputFile, formatted, 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	} else {
+		fmt.Print(string(formatted))
+	}
+	return nil
+}
+
+// field is a single generated struct field.
+type field struct {
+	GoName   string
+	JSONName string
+	GoType   string
+	Required bool
+	Enum     []string
+	Minimum  *float64
+	Maximum  *float64
+	MaxLen   *int
+}
+
+// structDef is a single generated Go struct.
+type structDef struct {
+	Name   string
+	Fields []field
+}
+
+// generator walks a JSON schema tree and accumulates the structs it needs,
+// deduplicating structurally identical anonymous object schemas by a
+// canonical-JSON hash so two occurrences of "the same shape" share one Go
+// type instead of generating near-duplicate structs.
+type generator struct {
+	root     map[string]any
+	structs  []*structDef
+	byHash   map[string]string // canonical shape hash -> generated type name
+	usedName map[string]bool
+}
+
+func newGenerator(root map[string]any) *generator {
+	return &generator{
+		root:     root,
+		byHash:   make(map[string]string),
+		usedName: make(map[string]bool),
+	}
+}
+
+// typeFor returns the Go type name for node, generating a struct for it
+// (and recursively for its properties/items) if it's an object or array of
+// objects. hint is the preferred type name (from a $defs key or the
+// enclosing field name); path is the JSON Pointer used in error messages.
+func (g *generator) typeFor(node map[string]any, hint, path string) (string, error) {
+	if ref, ok := node["$ref"].(string); ok {
+		target, name, err := g.resolveRef(ref)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		return g.typeFor(target, name, ref)
+	}
+
+	switch t, _ := node["type"].(string); t {
+	case "object", "":
+		if _, hasProps := node["properties"]; hasProps || t == "object" {
+			return g.objectType(node, hint, path)
+		}
+		return "map[string]any", nil
+	case "array":
+		items, _ := node["items"].(map[string]any)
+		if items == nil {
+			return "[]any", nil
+		}
+		itemType, err := g.typeFor(items, singular(hint), path+"/items")
+		if err != nil {
+			return "", err
+		}
+		return "[]" + itemType, nil
+	case "string":
+		return "string", nil
+	case "integer":
+		return "int64", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	default:
+		return "", fmt.Errorf("%s: unsupported schema type %q", path, t)
+	}
+}
+
+// objectType generates (or reuses, via structural dedup) a struct for an
+// object schema and returns its Go type name.
+func (g *generator) objectType(node map[string]any, hint, path string) (string, error) {
+	hash := canonicalHash(node)
+	if name, ok := g.byHash[hash]; ok {
+		return "*" + name, nil
+	}
+
+	name := g.uniqueName(exportedName(hint))
+	g.byHash[hash] = name
+
+	props, _ := node["properties"].(map[string]any)
+	required := stringSet(node["required"])
+
+	propNames := make([]string, 0, len(props))
+	for k := range props {
+		propNames = append(propNames, k)
+	}
+	sort.Strings(propNames)
+
+	def := &structDef{Name: name}
+	for _, propName := range propNames {
+		propNode, ok := props[propName].(map[string]any)
+		if !ok {
+			continue
+		}
+		goType, err := g.typeFor(propNode, exportedName(propName), path+"/properties/"+propName)
+		if err != nil {
+			return "", err
+		}
+
+		f := field{
+			GoName:   exportedName(propName),
+			JSONName: propName,
+			GoType:   goType,
+			Required: required[propName],
+		}
+		if enum, ok := propNode["enum"].([]any); ok {
+			for _, v := range enum {
+				if s, ok := v.(string); ok {
+					f.Enum = append(f.Enum, s)
+				}
+			}
+		}
+		if min, ok := propNode["minimum"].(float64); ok {
+			f.Minimum = &min
+		}
+		if max, ok := propNode["maximum"].(float64); ok {
+			f.Maximum = &max
+		}
+		if maxLen, ok := propNode["maxLength"].(float64); ok {
+			n := int(maxLen)
+			f.MaxLen = &n
+		}
+		def.Fields = append(def.Fields, f)
+	}
+
+	g.structs = append(g.structs, def)
+	return "*" + name, nil
+}
+
+// resolveRef resolves a local "#/$defs/Name"-style $ref against the schema
+// root, returning the target node and the name to use if a new type needs
+// generating for it.
+func (g *generator) resolveRef(ref string) (map[string]any, string, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, "", fmt.Errorf("only local $ref pointers are supported, got %q", ref)
+	}
+	parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+
+	var cur any = g.root
+	for _, part := range parts {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("cannot resolve $ref segment %q", part)
+		}
+		next, ok := m[part]
+		if !ok {
+			return nil, "", fmt.Errorf("$ref segment %q not found", part)
+		}
+		cur = next
+	}
+
+	target, ok := cur.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("$ref %q does not point at an object", ref)
+	}
+	return target, parts[len(parts)-1], nil
+}
+
+// uniqueName appends a numeric suffix if name is already taken, so two
+// differently-shaped types that happen to share a hint don't collide.
+func (g *generator) uniqueName(name string) string {
+	if name == "" {
+		name = "Object"
+	}
+	candidate := name
+	for i := 2; g.usedName[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	g.usedName[candidate] = true
+	return candidate
+}
+
+// canonicalHash produces a stable signature for an object schema so
+// structurally identical anonymous objects resolve to the same Go type.
+func canonicalHash(node map[string]any) string {
+	canon, _ := json.Marshal(sortedCopy(node))
+	return string(canon)
+}
+
+// sortedCopy deep-copies a JSON value with map keys in a deterministic
+// order, so json.Marshal produces a stable byte sequence to hash/compare.
+func sortedCopy(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make(map[string]any, len(val))
+		for _, k := range keys {
+			out[k] = sortedCopy(val[k])
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, e := range val {
+			out[i] = sortedCopy(e)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+func stringSet(raw any) map[string]bool {
+	set := make(map[string]bool)
+	list, _ := raw.([]any)
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// exportedName converts a JSON schema identifier (snake_case, kebab-case,
+// or already CamelCase) into an exported Go identifier.
+func exportedName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	})
+	if len(parts) == 0 {
+		return "Field"
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]))
+		b.WriteString(p[1:])
+	}
+	return b.String()
+}
+
+// singular strips a trailing "s" so an array field's item type doesn't end
+// up named e.g. "ItemsItem" when the array field was named "Items".
+func singular(name string) string {
+	if strings.HasSuffix(name, "ies") {
+		return name[:len(name)-3] + "y"
+	}
+	if strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		return name[:len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// render assembles the generated Go source as a string; gofmt normalizes
+// whitespace afterwards, so layout here only needs to be syntactically
+// valid, not pretty.
+func (g *generator) render(packageName, rootType, rawSchema string) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("// Code generated by gemma-cli/cmd/schemagen from a JSON schema. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", packageName)
+	b.WriteString("import (\n")
+	b.WriteString("\t\"context\"\n")
+	b.WriteString("\t\"encoding/json\"\n")
+	b.WriteString("\t\"fmt\"\n\n")
+	b.WriteString("\t\"github.com/egorsmkv/gemma-cli/pkg/schema\"\n")
+	b.WriteString("\t\"github.com/google/generative-ai-go/genai\"\n")
+	b.WriteString(")\n\n")
+
+	for _, def := range g.structs {
+		renderStruct(&b, def)
+		renderValidate(&b, def)
+	}
+
+	renderGenerate(&b, rootType, rawSchema)
+
+	return []byte(b.String()), nil
+}
+
+func renderStruct(b *strings.Builder, def *structDef) {
+	fmt.Fprintf(b, "// %s was generated from the input JSON schema.\n", def.Name)
+	fmt.Fprintf(b, "type %s struct {\n", def.Name)
+	for _, f := range def.Fields {
+		fmt.Fprintf(b, "\t%s %s `json:\"%s\"`\n", f.GoName, f.GoType, f.JSONName)
+	}
+	b.WriteString("}\n\n")
+}
+
+// renderValidate emits a Validate method that checks required string,
+// object and array fields are present and enforces any
+// enum/minimum/maximum/maxLength constraints the schema declared. Required
+// integer, number and boolean fields get no presence check.
+func renderValidate(b *strings.Builder, def *structDef) {
+	fmt.Fprintf(b, "// Validate checks %s against the constraints from the source schema.\n", def.Name)
+	fmt.Fprintf(b, "func (x *%s) Validate() error {\n", def.Name)
+
+	for _, f := range def.Fields {
+		if f.Required {
+			switch {
+			case f.GoType == "string":
+				fmt.Fprintf(b, "\tif x.%s == \"\" {\n\t\treturn fmt.Errorf(%q)\n\t}\n", f.GoName, f.JSONName+" is required")
+			case strings.HasPrefix(f.GoType, "*"):
+				fmt.Fprintf(b, "\tif x.%s == nil {\n\t\treturn fmt.Errorf(%q)\n\t}\n", f.GoName, f.JSONName+" is required")
+			case strings.HasPrefix(f.GoType, "["):
+				fmt.Fprintf(b, "\tif len(x.%s) == 0 {\n\t\treturn fmt.Errorf(%q)\n\t}\n", f.GoName, f.JSONName+" is required")
+			}
+		}
+
+		if len(f.Enum) > 0 {
+			quoted := make([]string, len(f.Enum))
+			for i, v := range f.Enum {
+				quoted[i] = strconv.Quote(v)
+			}
+			enumSwitch := fmt.Sprintf("\tswitch x.%s {\n\tcase %s:\n\tdefault:\n\t\treturn fmt.Errorf(%q, x.%s)\n\t}\n",
+				f.GoName, strings.Join(quoted, ", "), f.JSONName+" must be one of the allowed values, got %q", f.GoName)
+			if f.Required {
+				b.WriteString(enumSwitch)
+			} else {
+				// An optional enum field's Go zero value ("") means the
+				// model omitted it; only enforce the enum once it's set.
+				fmt.Fprintf(b, "\tif x.%s != \"\" {\n", f.GoName)
+				for _, line := range strings.Split(strings.TrimSuffix(enumSwitch, "\n"), "\n") {
+					b.WriteString("\t" + line + "\n")
+				}
+				b.WriteString("\t}\n")
+			}
+		}
+
+		if f.Minimum != nil {
+			fmt.Fprintf(b, "\tif float64(x.%s) < %v {\n\t\treturn fmt.Errorf(%q, x.%s)\n\t}\n",
+				f.GoName, *f.Minimum, f.JSONName+" must be >= "+strconv.FormatFloat(*f.Minimum, 'g', -1, 64)+", got %v", f.GoName)
+		}
+		if f.Maximum != nil {
+			fmt.Fprintf(b, "\tif float64(x.%s) > %v {\n\t\treturn fmt.Errorf(%q, x.%s)\n\t}\n",
+				f.GoName, *f.Maximum, f.JSONName+" must be <= "+strconv.FormatFloat(*f.Maximum, 'g', -1, 64)+", got %v", f.GoName)
+		}
+		if f.MaxLen != nil && f.GoType == "string" {
+			fmt.Fprintf(b, "\tif len(x.%s) > %d {\n\t\treturn fmt.Errorf(%q, len(x.%s))\n\t}\n",
+				f.GoName, *f.MaxLen, f.JSONName+" must be at most "+strconv.Itoa(*f.MaxLen)+" characters, got %d", f.GoName)
+		}
+	}
+
+	b.WriteString("\treturn nil\n}\n\n")
+}
+
+// renderGenerate emits a Generate helper that configures a
+// genai.GenerativeModel with the original schema (compiled via
+// pkg/schema, the same path the main gemma-cli binary uses) and unmarshals
+// the model's JSON response into rootType.
+func renderGenerate(b *strings.Builder, rootType, rawSchema string) {
+	fmt.Fprintf(b, "const rawSchema = %s\n\n", strconv.Quote(rawSchema))
+
+	fmt.Fprintf(b, "// Generate drives client with prompt and input, constraining the model's\n")
+	fmt.Fprintf(b, "// output to the schema this package was generated from, and unmarshals the\n")
+	fmt.Fprintf(b, "// result into %s.\n", rootType)
+	fmt.Fprintf(b, "func Generate(ctx context.Context, client *genai.Client, modelName, prompt, input string) (%s, error) {\n", rootType)
+	b.WriteString("\tvar zero " + rootType + "\n\n")
+	b.WriteString("\tvar schemaMap map[string]any\n")
+	b.WriteString("\tif err := json.Unmarshal([]byte(rawSchema), &schemaMap); err != nil {\n")
+	b.WriteString("\t\treturn zero, fmt.Errorf(\"failed to parse embedded schema: %w\", err)\n\t}\n\n")
+	b.WriteString("\tgenaiSchema, err := schema.Compile(schemaMap)\n")
+	b.WriteString("\tif err != nil {\n\t\treturn zero, fmt.Errorf(\"failed to compile schema: %w\", err)\n\t}\n\n")
+	b.WriteString("\tmodel := client.GenerativeModel(modelName)\n")
+	b.WriteString("\tmodel.ResponseMIMEType = \"application/json\"\n")
+	b.WriteString("\tmodel.ResponseSchema = genaiSchema\n\n")
+	b.WriteString("\tresp, err := model.GenerateContent(ctx, genai.Text(fmt.Sprintf(\"%s\\n\\nInput:\\n%s\", prompt, input)))\n")
+	b.WriteString("\tif err != nil {\n\t\treturn zero, fmt.Errorf(\"failed to generate content: %w\", err)\n\t}\n")
+	b.WriteString("\tif len(resp.Candidates) == 0 {\n\t\treturn zero, fmt.Errorf(\"no response candidates received\")\n\t}\n\n")
+	b.WriteString("\tvar text string\n")
+	b.WriteString("\tfor _, part := range resp.Candidates[0].Content.Parts {\n")
+	b.WriteString("\t\tif txt, ok := part.(genai.Text); ok {\n\t\t\ttext += string(txt)\n\t\t}\n\t}\n\n")
+	b.WriteString("\tvar out " + rootType + "\n")
+	b.WriteString("\tif err := json.Unmarshal([]byte(text), &out); err != nil {\n")
+	b.WriteString("\t\treturn zero, fmt.Errorf(\"failed to unmarshal response: %w\", err)\n\t}\n")
+	b.WriteString("\treturn out, nil\n}\n")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/egorsmkv/gemma-cli/pkg/schema"
+	"github.com/google/generative-ai-go/genai"
+	"gopkg.in/yaml.v3"
+)
+
+// maxFunctionCallTurns bounds the function-calling loop so a misbehaving
+// model (or tool) can't spin forever.
+const maxFunctionCallTurns = 8
+
+// functionCallTimeout is the deadline applied to a single command/endpoint
+// invocation triggered by a FunctionCall.
+const functionCallTimeout = 30 * time.Second
+
+// FunctionManifest is the top-level shape of a -functions file. It can be
+// authored as YAML or JSON; the format is picked from the file extension.
+type FunctionManifest struct {
+	Functions []FunctionSpec `json:"functions" yaml:"functions"`
+}
+
+// FunctionSpec declares a single callable tool: its name, description and
+// JSON-schema parameters as seen by the model, plus Command or Endpoint
+// describing how to actually run it (Command is used if both are set).
+type FunctionSpec struct {
+	Name        string         `json:"name" yaml:"name"`
+	Description string         `json:"description" yaml:"description"`
+	Parameters  map[string]any `json:"parameters" yaml:"parameters"`
+
+	// Command is run through "sh -c" with the call arguments available as
+	// JSON on stdin and in the GEMMA_FUNC_ARGS environment variable.
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
+
+	// Endpoint receives the call arguments as a JSON POST body and its
+	// response body is passed back to the model verbatim.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+}
+
+// loadFunctionManifest reads and parses a -functions file, choosing YAML or
+// JSON based on the file extension (.yaml/.yml vs everything else).
+func loadFunctionManifest(path string) (*FunctionManifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read functions file: %w", err)
+	}
+
+	var manifest FunctionManifest
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse functions file as YAML: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse functions file as JSON: %w", err)
+		}
+	}
+
+	for i, spec := range manifest.Functions {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("function at index %d is missing a name", i)
+		}
+		if spec.Command == "" && spec.Endpoint == "" {
+			return nil, fmt.Errorf("function %q must declare either command or endpoint", spec.Name)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// buildTools converts a FunctionManifest into the genai.Tool the model is
+// given alongside the existing ResponseSchema.
+func buildTools(manifest *FunctionManifest) (*genai.Tool, map[string]FunctionSpec, error) {
+	tool := &genai.Tool{}
+	specsByName := make(map[string]FunctionSpec, len(manifest.Functions))
+
+	for _, spec := range manifest.Functions {
+		params := spec.Parameters
+		if params == nil {
+			params = map[string]any{"type": "object"}
+		}
+
+		paramSchema, err := schema.Compile(params)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert parameters for function %q: %w", spec.Name, err)
+		}
+
+		tool.FunctionDeclarations = append(tool.FunctionDeclarations, &genai.FunctionDeclaration{
+			Name:        spec.Name,
+			Description: spec.Description,
+			Parameters:  paramSchema,
+		})
+		specsByName[spec.Name] = spec
+	}
+
+	return tool, specsByName, nil
+}
+
+// invokeFunctionCall dispatches a single model-requested FunctionCall to its
+// mapped command or HTTP endpoint and returns the raw result to report back.
+func invokeFunctionCall(ctx context.Context, spec FunctionSpec, call genai.FunctionCall) (string, error) {
+	argsJSON, err := json.Marshal(call.Args)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal arguments for function %q: %w", call.Name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, functionCallTimeout)
+	defer cancel()
+
+	switch {
+	case spec.Command != "":
+		cmd := exec.CommandContext(ctx, "sh", "-c", spec.Command)
+		cmd.Stdin = bytes.NewReader(argsJSON)
+		cmd.Env = append(os.Environ(), "GEMMA_FUNC_ARGS="+string(argsJSON))
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("command for function %q failed: %w (stderr: %s)", call.Name, err, stderr.String())
+		}
+		return stdout.String(), nil
+
+	case spec.Endpoint != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, spec.Endpoint, bytes.NewReader(argsJSON))
+		if err != nil {
+			return "", fmt.Errorf("failed to build request for function %q: %w", call.Name, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("endpoint for function %q failed: %w", call.Name, err)
+		}
+		defer resp.Body.Close()
+
+		var body bytes.Buffer
+		if _, err := body.ReadFrom(resp.Body); err != nil {
+			return "", fmt.Errorf("failed to read endpoint response for function %q: %w", call.Name, err)
+		}
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("endpoint for function %q returned status %d: %s", call.Name, resp.StatusCode, body.String())
+		}
+		return body.String(), nil
+
+	default:
+		return "", fmt.Errorf("function %q has neither a command nor an endpoint", call.Name)
+	}
+}
+
+// runFunctionCallLoop drives the model through successive FunctionCall/
+// FunctionResponse turns until it returns a response with no function calls
+// left to satisfy, or maxFunctionCallTurns is reached.
+func runFunctionCallLoop(ctx context.Context, chat *genai.ChatSession, specsByName map[string]FunctionSpec, resp *genai.GenerateContentResponse) (*genai.GenerateContentResponse, error) {
+	for turn := 0; turn < maxFunctionCallTurns; turn++ {
+		calls := functionCalls(resp)
+		if len(calls) == 0 {
+			return resp, nil
+		}
+
+		var responseParts []genai.Part
+		for _, call := range calls {
+			spec, ok := specsByName[call.Name]
+			if !ok {
+				return nil, fmt.Errorf("model called undeclared function %q", call.Name)
+			}
+
+			result, err := invokeFunctionCall(ctx, spec, call)
+			if err != nil {
+				return nil, err
+			}
+
+			responseParts = append(responseParts, genai.FunctionResponse{
+				Name: call.Name,
+				Response: map[string]any{
+					"result": result,
+				},
+			})
+		}
+
+		var err error
+		resp, err = chat.SendMessage(ctx, responseParts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send function responses: %w", err)
+		}
+	}
+
+	if len(functionCalls(resp)) == 0 {
+		return resp, nil
+	}
+	return nil, fmt.Errorf("exceeded %d function-calling turns without a final response", maxFunctionCallTurns)
+}
+
+// functionCalls extracts any FunctionCall parts from a response's first
+// candidate.
+func functionCalls(resp *genai.GenerateContentResponse) []genai.FunctionCall {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil
+	}
+
+	var calls []genai.FunctionCall
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if call, ok := part.(genai.FunctionCall); ok {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestLoadFunctionManifest_JSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "functions.json")
+	content := `{"functions":[{"name":"echo","description":"echoes input","command":"cat"}]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	manifest, err := loadFunctionManifest(path)
+	if err != nil {
+		t.Fatalf("loadFunctionManifest() error: %v", err)
+	}
+	if len(manifest.Functions) != 1 || manifest.Functions[0].Name != "echo" {
+		t.Fatalf("Functions = %+v, want one function named echo", manifest.Functions)
+	}
+}
+
+func TestLoadFunctionManifest_YAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "functions.yaml")
+	content := "functions:\n  - name: echo\n    description: echoes input\n    command: cat\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	manifest, err := loadFunctionManifest(path)
+	if err != nil {
+		t.Fatalf("loadFunctionManifest() error: %v", err)
+	}
+	if len(manifest.Functions) != 1 || manifest.Functions[0].Name != "echo" {
+		t.Fatalf("Functions = %+v, want one function named echo", manifest.Functions)
+	}
+}
+
+func TestLoadFunctionManifest_RequiresNameAndDispatchTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "missing name", content: `{"functions":[{"command":"cat"}]}`},
+		{name: "missing command and endpoint", content: `{"functions":[{"name":"echo"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "functions.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if _, err := loadFunctionManifest(path); err == nil {
+				t.Fatalf("loadFunctionManifest() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestBuildTools_DefaultsMissingParametersToObject(t *testing.T) {
+	manifest := &FunctionManifest{
+		Functions: []FunctionSpec{
+			{Name: "echo", Description: "echoes input", Command: "cat"},
+		},
+	}
+
+	tool, specsByName, err := buildTools(manifest)
+	if err != nil {
+		t.Fatalf("buildTools() error: %v", err)
+	}
+	if len(tool.FunctionDeclarations) != 1 {
+		t.Fatalf("FunctionDeclarations = %+v, want 1 entry", tool.FunctionDeclarations)
+	}
+	if tool.FunctionDeclarations[0].Parameters.Type != genai.TypeObject {
+		t.Fatalf("Parameters.Type = %v, want object", tool.FunctionDeclarations[0].Parameters.Type)
+	}
+	if _, ok := specsByName["echo"]; !ok {
+		t.Fatalf("specsByName = %v, want an \"echo\" entry", specsByName)
+	}
+}
+
+func TestInvokeFunctionCall_RunsCommandWithArgsOnStdin(t *testing.T) {
+	spec := FunctionSpec{Name: "echo", Command: "cat"}
+	call := genai.FunctionCall{Name: "echo", Args: map[string]any{"greeting": "hi"}}
+
+	out, err := invokeFunctionCall(context.Background(), spec, call)
+	if err != nil {
+		t.Fatalf("invokeFunctionCall() error: %v", err)
+	}
+	if out != `{"greeting":"hi"}` {
+		t.Fatalf("invokeFunctionCall() = %q, want the marshaled args echoed back", out)
+	}
+}
+
+func TestInvokeFunctionCall_SurfacesCommandFailure(t *testing.T) {
+	spec := FunctionSpec{Name: "fail", Command: "exit 1"}
+	call := genai.FunctionCall{Name: "fail", Args: map[string]any{}}
+
+	if _, err := invokeFunctionCall(context.Background(), spec, call); err == nil {
+		t.Fatalf("invokeFunctionCall() error = nil, want error for nonzero exit")
+	}
+}
+
+func TestInvokeFunctionCall_NoDispatchTarget(t *testing.T) {
+	spec := FunctionSpec{Name: "nowhere"}
+	call := genai.FunctionCall{Name: "nowhere", Args: map[string]any{}}
+
+	if _, err := invokeFunctionCall(context.Background(), spec, call); err == nil {
+		t.Fatalf("invokeFunctionCall() error = nil, want error for missing command/endpoint")
+	}
+}
+
+func TestFunctionCalls_ExtractsFromFirstCandidate(t *testing.T) {
+	resp := &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{
+				Content: &genai.Content{
+					Parts: []genai.Part{
+						genai.Text("some text"),
+						genai.FunctionCall{Name: "echo"},
+					},
+				},
+			},
+		},
+	}
+
+	calls := functionCalls(resp)
+	if len(calls) != 1 || calls[0].Name != "echo" {
+		t.Fatalf("functionCalls() = %+v, want one call named echo", calls)
+	}
+}
+
+func TestFunctionCalls_NoCandidates(t *testing.T) {
+	resp := &genai.GenerateContentResponse{}
+	if calls := functionCalls(resp); calls != nil {
+		t.Fatalf("functionCalls() = %+v, want nil", calls)
+	}
+}
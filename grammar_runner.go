@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/egorsmkv/gemma-cli/pkg/grammar"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// validateAgainstGrammar checks responseText against gram and, on mismatch,
+// re-prompts the model with the parse error appended to the conversation,
+// retrying up to maxRetries times before giving up.
+func validateAgainstGrammar(ctx context.Context, chat *genai.ChatSession, gram *grammar.Grammar, responseText string, maxRetries int) (string, error) {
+	for attempt := 0; ; attempt++ {
+		err := grammar.Validate(gram, responseText)
+		if err == nil {
+			return responseText, nil
+		}
+		if attempt >= maxRetries {
+			return "", fmt.Errorf("output did not match grammar after %d retries: %w", maxRetries, err)
+		}
+
+		retryPrompt := fmt.Sprintf("Your previous response did not match the required grammar: %v\n\nPlease respond again, following the grammar exactly.", err)
+		resp, sendErr := chat.SendMessage(ctx, genai.Text(retryPrompt))
+		if sendErr != nil {
+			return "", fmt.Errorf("failed to re-prompt after grammar mismatch: %w", sendErr)
+		}
+
+		responseText = ""
+		if len(resp.Candidates) > 0 {
+			for _, part := range resp.Candidates[0].Content.Parts {
+				if txt, ok := part.(genai.Text); ok {
+					responseText += string(txt)
+				}
+			}
+		}
+	}
+}
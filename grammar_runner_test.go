@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/egorsmkv/gemma-cli/pkg/grammar"
+)
+
+func TestValidateAgainstGrammar_ReturnsImmediatelyOnMatch(t *testing.T) {
+	gram, err := grammar.Parse(`root ::= "ok"`)
+	if err != nil {
+		t.Fatalf("grammar.Parse() error: %v", err)
+	}
+
+	// chat is never touched when the first attempt already matches, so a nil
+	// *genai.ChatSession is safe here.
+	got, err := validateAgainstGrammar(context.Background(), nil, gram, "ok", 3)
+	if err != nil {
+		t.Fatalf("validateAgainstGrammar() error: %v", err)
+	}
+	if got != "ok" {
+		t.Fatalf("validateAgainstGrammar() = %q, want %q", got, "ok")
+	}
+}
+
+func TestValidateAgainstGrammar_FailsWithoutRetryingWhenMaxRetriesIsZero(t *testing.T) {
+	gram, err := grammar.Parse(`root ::= "ok"`)
+	if err != nil {
+		t.Fatalf("grammar.Parse() error: %v", err)
+	}
+
+	// maxRetries=0 means attempt 0 already exhausts the budget, so
+	// validateAgainstGrammar must return the grammar error without ever
+	// calling chat.SendMessage — again safe to exercise with a nil chat.
+	_, err = validateAgainstGrammar(context.Background(), nil, gram, "nope", 0)
+	if err == nil {
+		t.Fatalf("validateAgainstGrammar() error = nil, want a grammar mismatch error")
+	}
+}
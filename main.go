@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/egorsmkv/gemma-cli/env"
+	"github.com/egorsmkv/gemma-cli/pkg/grammar"
+	"github.com/egorsmkv/gemma-cli/pkg/schema"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
@@ -28,27 +31,67 @@ var DefaultSchema = map[string]any{
 
 // Config holds the application configuration
 type Config struct {
-	APIKey     string
-	PromptFile string
-	Model      string
-	SchemaFile string
-	OutputFile string
-	InputFile  string
+	APIKey         string
+	PromptFile     string
+	Model          string
+	SchemaFile     string
+	OutputFile     string
+	InputFile      string
+	FunctionsFile  string
+	ServeAddr      string
+	GrammarFile    string
+	GrammarRetries int
+	InputDir       string
+	CheckpointFile string
+	Concurrency    int
+	MaxRetries     int
+	RetryBase      time.Duration
+	RPM            int
+	Stream         bool
 }
 
 func main() {
 	// Parse command line flags
 	var (
-		promptFile = flag.String("prompt", "", "Path to prompt file (required)")
-		model      = flag.String("model", "gemini-1.5-flash", "Model to use (default: gemini-1.5-flash)")
-		schemaFile = flag.String("schema", "", "Path to JSON schema file (optional)")
-		outputFile = flag.String("output", "", "Output file path (default: stdout)")
-		inputFile  = flag.String("input", "", "Input file path (required)")
+		promptFile     = flag.String("prompt", "", "Path to prompt file (required)")
+		model          = flag.String("model", "gemini-1.5-flash", "Model to use (default: gemini-1.5-flash)")
+		schemaFile     = flag.String("schema", "", "Path to JSON schema file (optional)")
+		outputFile     = flag.String("output", "", "Output file path (default: stdout)")
+		inputFile      = flag.String("input", "", "Input file path (required)")
+		functionsFile  = flag.String("functions", "", "Path to a function/tool manifest (YAML or JSON, optional)")
+		serveAddr      = flag.String("serve", "", "Start an OpenAI-compatible HTTP server on this address (e.g. :8080) instead of a single run")
+		grammarFile    = flag.String("grammar", "", "Path to a GBNF grammar file, as an alternative to -schema (optional)")
+		grammarRetries = flag.Int("grammar-retries", 3, "Max re-prompts when output doesn't match -grammar")
+		inputDir       = flag.String("input-dir", "", "Directory of per-record input files to process in batch mode (alternative to a .jsonl -input)")
+		checkpointFile = flag.String("checkpoint", "", "Checkpoint file recording completed record IDs, for resuming a batch run (default: <output>.checkpoint)")
+		concurrency    = flag.Int("concurrency", 4, "Number of concurrent workers in batch mode")
+		maxRetries     = flag.Int("max-retries", 3, "Max retries per record on 429/5xx errors in batch mode")
+		retryBase      = flag.Duration("retry-base", 500*time.Millisecond, "Base delay for exponential backoff between retries in batch mode")
+		rpm            = flag.Int("rpm", 0, "Rate limit requests per minute in batch mode (0 = unlimited)")
+		stream         = flag.Bool("stream", false, "Stream each batch call via GenerateContentStream, flushing partial output to stderr")
 	)
 	flag.Parse()
 
+	// The -serve flag runs an HTTP server instead of the one-shot prompt
+	// flow, so it skips the -prompt/-input requirement below.
+	if *serveAddr != "" {
+		env.LoadFromFile(".env")
+		apiKey := env.GetAsString("GEMINI_API_KEY")
+		if apiKey == "" {
+			fmt.Fprintf(os.Stderr, "Error: GEMINI_API_KEY environment variable is required\n")
+			os.Exit(1)
+		}
+
+		config := Config{APIKey: apiKey, Model: *model, ServeAddr: *serveAddr}
+		if err := runServer(config); err != nil {
+			slog.Error("Server error", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Validate required flags
-	if *promptFile == "" || *inputFile == "" {
+	if *promptFile == "" || (*inputFile == "" && *inputDir == "") {
 		fmt.Fprintf(os.Stderr, "Usage: %s -prompt=<prompt.txt> -input=<input.txt> [options]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nRequired flags:\n")
 		fmt.Fprintf(os.Stderr, "  -prompt=<file>   Path to prompt file\n")
@@ -57,6 +100,18 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  -model=<model>   Model to use (default: gemini-1.5-flash)\n")
 		fmt.Fprintf(os.Stderr, "  -schema=<file>   Path to JSON schema file\n")
 		fmt.Fprintf(os.Stderr, "  -output=<file>   Output file path (default: stdout)\n")
+		fmt.Fprintf(os.Stderr, "  -functions=<file> Path to a function/tool manifest (YAML or JSON)\n")
+		fmt.Fprintf(os.Stderr, "  -serve=<addr>    Start an OpenAI-compatible HTTP server instead of a single run\n")
+		fmt.Fprintf(os.Stderr, "  -grammar=<file>  Path to a GBNF grammar file, as an alternative to -schema\n")
+		fmt.Fprintf(os.Stderr, "  -grammar-retries=<n> Max re-prompts when output doesn't match -grammar (default: 3)\n")
+		fmt.Fprintf(os.Stderr, "\nBatch mode (when -input is a .jsonl file or -input-dir is set):\n")
+		fmt.Fprintf(os.Stderr, "  -input-dir=<dir> Directory of per-record input files\n")
+		fmt.Fprintf(os.Stderr, "  -checkpoint=<file> Checkpoint file for resuming (default: <output>.checkpoint)\n")
+		fmt.Fprintf(os.Stderr, "  -concurrency=<n> Number of concurrent workers (default: 4)\n")
+		fmt.Fprintf(os.Stderr, "  -max-retries=<n> Max retries per record on 429/5xx (default: 3)\n")
+		fmt.Fprintf(os.Stderr, "  -retry-base=<d>  Base delay for exponential backoff (default: 500ms)\n")
+		fmt.Fprintf(os.Stderr, "  -rpm=<n>         Rate limit requests per minute (default: unlimited)\n")
+		fmt.Fprintf(os.Stderr, "  -stream          Stream each call, flushing partial output to stderr\n")
 		fmt.Fprintf(os.Stderr, "\nEnvironment variables:\n")
 		fmt.Fprintf(os.Stderr, "  GEMINI_API_KEY   Google Gemini API key (required)\n")
 		os.Exit(1)
@@ -71,12 +126,36 @@ func main() {
 	}
 
 	config := Config{
-		APIKey:     apiKey,
-		PromptFile: *promptFile,
-		Model:      *model,
-		SchemaFile: *schemaFile,
-		OutputFile: *outputFile,
-		InputFile:  *inputFile,
+		APIKey:         apiKey,
+		PromptFile:     *promptFile,
+		Model:          *model,
+		SchemaFile:     *schemaFile,
+		OutputFile:     *outputFile,
+		InputFile:      *inputFile,
+		FunctionsFile:  *functionsFile,
+		GrammarFile:    *grammarFile,
+		GrammarRetries: *grammarRetries,
+		InputDir:       *inputDir,
+		CheckpointFile: *checkpointFile,
+		Concurrency:    *concurrency,
+		MaxRetries:     *maxRetries,
+		RetryBase:      *retryBase,
+		RPM:            *rpm,
+		Stream:         *stream,
+	}
+	if config.CheckpointFile == "" {
+		config.CheckpointFile = config.OutputFile + ".checkpoint"
+	}
+
+	// Batch mode replaces the single prompt/response flow with a worker
+	// pool over many records when -input is a JSONL file or -input-dir is
+	// set; otherwise fall through to the original single-file run.
+	if isBatchInput(config.InputFile, config.InputDir) {
+		if err := runBatch(config); err != nil {
+			slog.Error("Batch run error", "error", err)
+			os.Exit(1)
+		}
+		return
 	}
 
 	if err := run(config); err != nil {
@@ -99,17 +178,45 @@ func run(config Config) error {
 	}
 
 	// Load schema
-	var schema map[string]any
+	var jsonSchema map[string]any
 	if config.SchemaFile != "" {
 		schemaContent, err := os.ReadFile(config.SchemaFile)
 		if err != nil {
 			return fmt.Errorf("failed to read schema file: %w", err)
 		}
-		if err := json.Unmarshal(schemaContent, &schema); err != nil {
+		if err := json.Unmarshal(schemaContent, &jsonSchema); err != nil {
 			return fmt.Errorf("failed to parse schema file: %w", err)
 		}
 	} else {
-		schema = DefaultSchema
+		jsonSchema = DefaultSchema
+	}
+
+	// Load the grammar, if any. When no -schema was given, try lowering the
+	// grammar's JSON-shaped subset into a schema so the model is steered
+	// the same way -schema would steer it; either way, the raw output is
+	// validated against the full grammar further down. If the grammar isn't
+	// JSON-shaped and no -schema was given, jsonMode is turned off below so
+	// we don't steer the model toward an unrelated schema or force-parse
+	// non-JSON grammar output as JSON.
+	jsonMode := true
+	var gram *grammar.Grammar
+	if config.GrammarFile != "" {
+		grammarContent, err := os.ReadFile(config.GrammarFile)
+		if err != nil {
+			return fmt.Errorf("failed to read grammar file: %w", err)
+		}
+		gram, err = grammar.Parse(string(grammarContent))
+		if err != nil {
+			return fmt.Errorf("failed to parse grammar file: %w", err)
+		}
+		if config.SchemaFile == "" {
+			lowered, lowerErr := grammar.LowerToJSONSchema(gram)
+			if lowerErr != nil {
+				jsonMode = false
+			} else {
+				jsonSchema = lowered
+			}
+		}
 	}
 
 	// Create Gemini client
@@ -123,26 +230,55 @@ func run(config Config) error {
 	// Get the model
 	model := client.GenerativeModel(config.Model)
 
-	// Configure the model for JSON output
-	model.ResponseMIMEType = "application/json"
+	// Configure the model for JSON output, unless -grammar describes
+	// non-JSON text and no -schema was given to steer it with, in which
+	// case the model generates free-form text that the grammar itself
+	// constrains instead.
+	if jsonMode {
+		model.ResponseMIMEType = "application/json"
 
-	// Set the response schema by converting the JSON schema to genai.Schema
-	genaiSchema, err := convertJSONSchemaToGenaiSchema(schema)
-	if err != nil {
-		return fmt.Errorf("failed to convert schema: %w", err)
+		genaiSchema, err := schema.Compile(jsonSchema)
+		if err != nil {
+			return fmt.Errorf("failed to convert schema: %w", err)
+		}
+		model.ResponseSchema = genaiSchema
 	}
 
-	model.ResponseSchema = genaiSchema
+	// Load the function/tool manifest, if any, and attach it to the model
+	// alongside the response schema.
+	var specsByName map[string]FunctionSpec
+	if config.FunctionsFile != "" {
+		manifest, err := loadFunctionManifest(config.FunctionsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load functions file: %w", err)
+		}
+
+		tool, specs, err := buildTools(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to build tools: %w", err)
+		}
+		model.Tools = []*genai.Tool{tool}
+		specsByName = specs
+	}
 
 	// Create the full prompt
 	fullPrompt := fmt.Sprintf("%s\n\nInput:\n%s", string(promptContent), string(inputContent))
 
-	// Generate content
-	resp, err := model.GenerateContent(ctx, genai.Text(fullPrompt))
+	// Generate content, following any FunctionCall turns the model requests
+	// until it settles on a terminal response.
+	chat := model.StartChat()
+	resp, err := chat.SendMessage(ctx, genai.Text(fullPrompt))
 	if err != nil {
 		return fmt.Errorf("failed to generate content: %w", err)
 	}
 
+	if len(specsByName) > 0 {
+		resp, err = runFunctionCallLoop(ctx, chat, specsByName, resp)
+		if err != nil {
+			return fmt.Errorf("failed to complete function-calling loop: %w", err)
+		}
+	}
+
 	// Extract the response
 	if len(resp.Candidates) == 0 {
 		return fmt.Errorf("no response candidates received")
@@ -155,106 +291,37 @@ func run(config Config) error {
 		}
 	}
 
-	// Parse and format the JSON response
-	var jsonResponse any
-	if err := json.Unmarshal([]byte(responseText), &jsonResponse); err != nil {
-		return fmt.Errorf("failed to parse response as JSON: %w", err)
-	}
-
-	// Format with 2-space indentation
-	formattedJSON, err := json.MarshalIndent(jsonResponse, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to format JSON response: %w", err)
-	}
-
-	// Write output
-	if config.OutputFile != "" {
-		if err := os.WriteFile(config.OutputFile, formattedJSON, 0644); err != nil {
-			return fmt.Errorf("failed to write output file: %w", err)
-		}
-	} else {
-		fmt.Println(string(formattedJSON))
-	}
-
-	return nil
-}
-
-// convertJSONSchemaToGenaiSchema converts a JSON schema map to a genai.Schema
-func convertJSONSchemaToGenaiSchema(jsonSchema map[string]any) (*genai.Schema, error) {
-	schema := &genai.Schema{}
-
-	// Set type
-	if typeStr, ok := jsonSchema["type"].(string); ok {
-		switch typeStr {
-		case "object":
-			schema.Type = genai.TypeObject
-		case "array":
-			schema.Type = genai.TypeArray
-		case "string":
-			schema.Type = genai.TypeString
-		case "number":
-			schema.Type = genai.TypeNumber
-		case "integer":
-			schema.Type = genai.TypeInteger
-		case "boolean":
-			schema.Type = genai.TypeBoolean
-		default:
-			return nil, fmt.Errorf("unsupported type: %s", typeStr)
+	if gram != nil {
+		responseText, err = validateAgainstGrammar(ctx, chat, gram, responseText, config.GrammarRetries)
+		if err != nil {
+			return err
 		}
 	}
 
-	// Set description
-	if desc, ok := jsonSchema["description"].(string); ok {
-		schema.Description = desc
-	}
-
-	// Set properties for object type
-	if props, ok := jsonSchema["properties"].(map[string]any); ok {
-		schema.Properties = make(map[string]*genai.Schema)
-		for key, prop := range props {
-			if propMap, ok := prop.(map[string]any); ok {
-				propSchema, err := convertJSONSchemaToGenaiSchema(propMap)
-				if err != nil {
-					return nil, fmt.Errorf("failed to convert property %s: %w", key, err)
-				}
-				schema.Properties[key] = propSchema
-			}
+	output := []byte(responseText)
+	if jsonMode {
+		// Parse and format the JSON response
+		var jsonResponse any
+		if err := json.Unmarshal([]byte(responseText), &jsonResponse); err != nil {
+			return fmt.Errorf("failed to parse response as JSON: %w", err)
 		}
-	}
 
-	// Set items for array type
-	if items, ok := jsonSchema["items"].(map[string]any); ok {
-		itemSchema, err := convertJSONSchemaToGenaiSchema(items)
+		// Format with 2-space indentation
+		formattedJSON, err := json.MarshalIndent(jsonResponse, "", "  ")
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert items schema: %w", err)
-		}
-		schema.Items = itemSchema
-	}
-
-	// Set required fields
-	if required, ok := jsonSchema["required"].([]any); ok {
-		schema.Required = make([]string, len(required))
-		for i, req := range required {
-			if reqStr, ok := req.(string); ok {
-				schema.Required[i] = reqStr
-			}
+			return fmt.Errorf("failed to format JSON response: %w", err)
 		}
+		output = formattedJSON
 	}
 
-	// Set enum values
-	if enum, ok := jsonSchema["enum"].([]any); ok {
-		schema.Enum = make([]string, len(enum))
-		for i, e := range enum {
-			if eStr, ok := e.(string); ok {
-				schema.Enum[i] = eStr
-			}
+	// Write output
+	if config.OutputFile != "" {
+		if err := os.WriteFile(config.OutputFile, output, 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
 		}
+	} else {
+		fmt.Println(string(output))
 	}
 
-	// Set format
-	if format, ok := jsonSchema["format"].(string); ok {
-		schema.Format = format
-	}
-
-	return schema, nil
+	return nil
 }
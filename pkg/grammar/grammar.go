@@ -0,0 +1,206 @@
+// Package grammar parses GBNF-style context-free grammars — the dialect
+// used by llama.cpp and LocalAI's grammar package — and provides two ways
+// to use them: lowering the JSON-shaped subset to a JSON Schema (see
+// jsonshape.go) and validating generated text against the full grammar
+// (see validate.go).
+package grammar
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Expr is a node in a parsed grammar rule's right-hand side.
+type Expr interface {
+	isExpr()
+}
+
+// Literal matches an exact (already-unescaped) string.
+type Literal string
+
+// RuleRef refers to another rule by name.
+type RuleRef string
+
+// Sequence matches its elements back to back.
+type Sequence []Expr
+
+// Alternation matches any one of its branches.
+type Alternation []Expr
+
+// Repeat matches Inner between Min and Max times. Max of -1 means
+// unbounded, mirroring GBNF's "*" (Min=0), "+" (Min=1) and "?" (Min=0,
+// Max=1) suffixes.
+type Repeat struct {
+	Inner Expr
+	Min   int
+	Max   int
+}
+
+// CharClass matches a single rune against a GBNF "[...]" character class.
+type CharClass struct {
+	Negated bool
+	Ranges  []CharRange
+}
+
+// CharRange is an inclusive rune range; Lo == Hi for a single character.
+type CharRange struct {
+	Lo, Hi rune
+}
+
+func (Literal) isExpr()     {}
+func (RuleRef) isExpr()     {}
+func (Sequence) isExpr()    {}
+func (Alternation) isExpr() {}
+func (Repeat) isExpr()      {}
+func (CharClass) isExpr()   {}
+
+// Rule is a single named production.
+type Rule struct {
+	Name string
+	Expr Expr
+}
+
+// Grammar is a fully parsed GBNF document.
+type Grammar struct {
+	Rules map[string]*Rule
+	Root  string
+}
+
+// matches reports whether c falls in the class, honoring Negated.
+func (cc CharClass) matches(c rune) bool {
+	in := false
+	for _, r := range cc.Ranges {
+		if c >= r.Lo && c <= r.Hi {
+			in = true
+			break
+		}
+	}
+	if cc.Negated {
+		return !in
+	}
+	return in
+}
+
+// Parse parses a GBNF grammar document into a Grammar. Rule definitions are
+// split on the "name ::=" marker, so a rule's body runs until the next
+// "name ::=" or the end of input; this is the common convention for GBNF
+// source files and keeps the parser independent of exact line breaks.
+func Parse(source string) (*Grammar, error) {
+	defs, err := splitRuleDefinitions(stripComments(source))
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("grammar has no rule definitions")
+	}
+
+	g := &Grammar{Rules: make(map[string]*Rule, len(defs)), Root: defs[0].name}
+	for _, def := range defs {
+		if _, exists := g.Rules[def.name]; exists {
+			return nil, fmt.Errorf("rule %q is defined more than once", def.name)
+		}
+		p := &parser{src: []rune(def.body)}
+		expr, err := p.parseAlternation()
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", def.name, err)
+		}
+		p.skipWS()
+		if !p.atEnd() {
+			return nil, fmt.Errorf("rule %q: unexpected trailing input %q", def.name, string(p.src[p.pos:]))
+		}
+		g.Rules[def.name] = &Rule{Name: def.name, Expr: expr}
+	}
+	if _, ok := g.Rules["root"]; ok {
+		g.Root = "root"
+	}
+	return g, nil
+}
+
+type ruleDef struct {
+	name string
+	body string
+}
+
+// marker records where a "name ::=" header was found while scanning the
+// grammar source for rule boundaries.
+type marker struct {
+	name      string
+	bodyStart int
+}
+
+// stripComments removes GBNF "#" line comments.
+func stripComments(source string) string {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitRuleDefinitions scans source for "identifier ::=" markers and returns
+// the name/body pairs between them, in order.
+func splitRuleDefinitions(source string) ([]ruleDef, error) {
+	var defs []ruleDef
+	runes := []rune(source)
+	i := 0
+	n := len(runes)
+
+	var markers []marker
+
+	for i < n {
+		for i < n && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		start := i
+		for i < n && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '-' || runes[i] == '_') {
+			i++
+		}
+		if i == start {
+			i++
+			continue
+		}
+		name := string(runes[start:i])
+
+		j := i
+		for j < n && (runes[j] == ' ' || runes[j] == '\t') {
+			j++
+		}
+		if j+2 < n && runes[j] == ':' && runes[j+1] == ':' && runes[j+2] == '=' {
+			markers = append(markers, marker{name: name, bodyStart: j + 3})
+			i = j + 3
+		}
+	}
+
+	if len(markers) == 0 {
+		return nil, fmt.Errorf("no %q markers found in grammar source", "::=")
+	}
+
+	for k, m := range markers {
+		end := n
+		if k+1 < len(markers) {
+			// The next marker's body starts right after its own "::=";
+			// its name (and any leading whitespace) belongs to this rule's
+			// end boundary.
+			end = headerStart(runes, markers[k+1])
+		}
+		defs = append(defs, ruleDef{name: m.name, body: string(runes[m.bodyStart:end])})
+	}
+	return defs, nil
+}
+
+// headerStart walks backwards from a marker's body start to find where its
+// "name ::=" header began, so the previous rule's body excludes it.
+func headerStart(runes []rune, m marker) int {
+	pos := m.bodyStart - 3 // before "::="
+	for pos > 0 && (runes[pos-1] == ' ' || runes[pos-1] == '\t') {
+		pos--
+	}
+	pos -= len([]rune(m.name))
+	if pos < 0 {
+		pos = 0
+	}
+	return pos
+}
@@ -0,0 +1,247 @@
+package grammar
+
+import "testing"
+
+func TestParseAndValidate_Literal(t *testing.T) {
+	g, err := Parse(`root ::= "hello"`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if err := Validate(g, "hello"); err != nil {
+		t.Fatalf("Validate(%q) error: %v", "hello", err)
+	}
+	if err := Validate(g, "goodbye"); err == nil {
+		t.Fatalf("Validate(%q) = nil, want error", "goodbye")
+	}
+}
+
+func TestParseAndValidate_Alternation(t *testing.T) {
+	g, err := Parse(`root ::= "red" | "green" | "blue"`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, ok := range []string{"red", "green", "blue"} {
+		if err := Validate(g, ok); err != nil {
+			t.Errorf("Validate(%q) error: %v", ok, err)
+		}
+	}
+	if err := Validate(g, "purple"); err == nil {
+		t.Fatalf("Validate(%q) = nil, want error", "purple")
+	}
+}
+
+func TestParseAndValidate_Repetition(t *testing.T) {
+	g, err := Parse(`root ::= "a"*`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, ok := range []string{"", "a", "aaaa"} {
+		if err := Validate(g, ok); err != nil {
+			t.Errorf("Validate(%q) error: %v", ok, err)
+		}
+	}
+	if err := Validate(g, "aab"); err == nil {
+		t.Fatalf("Validate(%q) = nil, want error", "aab")
+	}
+
+	gPlus, err := Parse(`root ::= "a"+`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if err := Validate(gPlus, ""); err == nil {
+		t.Fatalf("Validate(\"\") on \"a\"+ = nil, want error (requires at least one)")
+	}
+	if err := Validate(gPlus, "aaa"); err != nil {
+		t.Fatalf("Validate(\"aaa\") error: %v", err)
+	}
+}
+
+func TestParseAndValidate_CharClassAndGroup(t *testing.T) {
+	g, err := Parse(`root ::= [a-z]+ ("-" [a-z]+)?`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, ok := range []string{"abc", "abc-def"} {
+		if err := Validate(g, ok); err != nil {
+			t.Errorf("Validate(%q) error: %v", ok, err)
+		}
+	}
+	if err := Validate(g, "ABC"); err == nil {
+		t.Fatalf("Validate(%q) = nil, want error", "ABC")
+	}
+}
+
+func TestParseAndValidate_StringProduction(t *testing.T) {
+	g, err := Parse(`
+root   ::= string
+string ::= "\"" char* "\""
+char   ::= [a-zA-Z]
+`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if err := Validate(g, `"hello"`); err != nil {
+		t.Fatalf(`Validate(%q) error: %v`, `"hello"`, err)
+	}
+	if err := Validate(g, `hello`); err == nil {
+		t.Fatalf("Validate(%q) = nil, want error (missing quotes)", "hello")
+	}
+}
+
+func TestParseAndValidate_BoundedRepeat(t *testing.T) {
+	g, err := Parse(`root ::= [0-9a-fA-F]{4}`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, ok := range []string{"1234", "abcd", "AB12"} {
+		if err := Validate(g, ok); err != nil {
+			t.Errorf("Validate(%q) error: %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"123", "12345", "12g4"} {
+		if err := Validate(g, bad); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", bad)
+		}
+	}
+
+	gRange, err := Parse(`root ::= "a"{2,4}`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, ok := range []string{"aa", "aaa", "aaaa"} {
+		if err := Validate(gRange, ok); err != nil {
+			t.Errorf("Validate(%q) error: %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"a", "aaaaa"} {
+		if err := Validate(gRange, bad); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", bad)
+		}
+	}
+
+	gOpenEnded, err := Parse(`root ::= "a"{2,}`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if err := Validate(gOpenEnded, "a"); err == nil {
+		t.Fatalf("Validate(\"a\") on \"a\"{2,} = nil, want error (below minimum)")
+	}
+	if err := Validate(gOpenEnded, "aaaaaa"); err != nil {
+		t.Fatalf("Validate(\"aaaaaa\") error: %v", err)
+	}
+}
+
+func TestParseAndValidate_HexEscapes(t *testing.T) {
+	g, err := Parse(`root ::= "\x41\u0042"`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if err := Validate(g, "AB"); err != nil {
+		t.Fatalf(`Validate("AB") error: %v`, err)
+	}
+
+	// Mirrors the canonical JSON-string dialect's "no control characters"
+	// production: \x7F and \x00-\x1F must be excluded, not read as the
+	// literal characters 'x','7','F'.
+	gClass, err := Parse(`root ::= [^"\\\x7F\x00-\x1F]*`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if err := Validate(gClass, "hi"); err != nil {
+		t.Fatalf(`Validate("hi") error: %v`, err)
+	}
+	if err := Validate(gClass, "\x7F"); err == nil {
+		t.Fatalf("Validate(\"\\x7F\") = nil, want error (DEL must be excluded)")
+	}
+	if err := Validate(gClass, "\x01"); err == nil {
+		t.Fatalf("Validate(\"\\x01\") = nil, want error (control char must be excluded)")
+	}
+}
+
+func TestParse_InvalidHexEscape(t *testing.T) {
+	if _, err := Parse(`root ::= "\xG1"`); err == nil {
+		t.Fatalf(`Parse(root ::= "\xG1") error = nil, want error for invalid hex digits`)
+	}
+	if _, err := Parse(`root ::= "\x4"`); err == nil {
+		t.Fatalf(`Parse(root ::= "\x4") error = nil, want error for incomplete hex escape`)
+	}
+}
+
+func TestLowerToJSONSchema_ObjectWithMembers(t *testing.T) {
+	g, err := Parse(`
+root  ::= "{" "\"name\"" ":" string "," "\"age\"" ":" number "}"
+string ::= "string"
+`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	schema, err := LowerToJSONSchema(g)
+	if err != nil {
+		t.Fatalf("LowerToJSONSchema() error: %v", err)
+	}
+	if schema["type"] != "object" {
+		t.Fatalf("type = %v, want object", schema["type"])
+	}
+	props, ok := schema["properties"].(map[string]any)
+	if !ok {
+		t.Fatalf("properties missing or wrong type: %#v", schema["properties"])
+	}
+	name, ok := props["name"].(map[string]any)
+	if !ok || name["type"] != "string" {
+		t.Fatalf("properties[name] = %#v, want string schema", props["name"])
+	}
+	age, ok := props["age"].(map[string]any)
+	if !ok || age["type"] != "number" {
+		t.Fatalf("properties[age] = %#v, want number schema", props["age"])
+	}
+}
+
+func TestLowerToJSONSchema_Array(t *testing.T) {
+	g, err := Parse(`root ::= "[" string ("," string)* "]"
+string ::= "\"" [a-z]* "\""`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	schema, err := LowerToJSONSchema(g)
+	if err != nil {
+		t.Fatalf("LowerToJSONSchema() error: %v", err)
+	}
+	if schema["type"] != "array" {
+		t.Fatalf("type = %v, want array", schema["type"])
+	}
+	items, ok := schema["items"].(map[string]any)
+	if !ok || items["type"] != "string" {
+		t.Fatalf("items = %#v, want string schema", schema["items"])
+	}
+}
+
+func TestLowerToJSONSchema_Enum(t *testing.T) {
+	g, err := Parse(`root ::= "\"red\"" | "\"green\"" | "\"blue\""`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	schema, err := LowerToJSONSchema(g)
+	if err != nil {
+		t.Fatalf("LowerToJSONSchema() error: %v", err)
+	}
+	enum, ok := schema["enum"].([]string)
+	if !ok || len(enum) != 3 {
+		t.Fatalf("enum = %#v, want 3 string values", schema["enum"])
+	}
+}
+
+func TestLowerToJSONSchema_RecursiveRuleErrors(t *testing.T) {
+	g, err := Parse(`
+root ::= node
+node ::= "(" node ")"
+`)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if _, err := LowerToJSONSchema(g); err == nil {
+		t.Fatalf("LowerToJSONSchema() error = nil, want error for recursive non-JSON rule")
+	}
+}
@@ -0,0 +1,226 @@
+package grammar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LowerToJSONSchema synthesizes a JSON Schema for the subset of g that is
+// JSON-shaped: object/array/string/enum productions built from the usual
+// GBNF conventions (a literal "{"/"}" or "["/"]" pair, "key":value members,
+// and alternations of string literals for enums). It errors out rather than
+// guessing when a rule can't be represented this way, e.g. a rule built
+// from raw character classes or a recursive non-object/array production.
+func LowerToJSONSchema(g *Grammar) (map[string]any, error) {
+	root, ok := g.Rules[g.Root]
+	if !ok {
+		return nil, fmt.Errorf("grammar has no rule named %q", g.Root)
+	}
+	return lowerExpr(g, root.Expr, map[string]bool{})
+}
+
+// lowerExpr lowers a single production. visiting tracks rule names
+// currently being expanded so a recursive rule reports a clear error
+// instead of looping forever.
+func lowerExpr(g *Grammar, expr Expr, visiting map[string]bool) (map[string]any, error) {
+	switch e := expr.(type) {
+	case RuleRef:
+		return lowerRuleRef(g, string(e), visiting)
+
+	case Literal:
+		return map[string]any{"type": "string", "enum": []string{unquoteLiteral(string(e))}}, nil
+
+	case Alternation:
+		return lowerEnum(e)
+
+	case Sequence:
+		return lowerSequence(g, e, visiting)
+
+	default:
+		return nil, fmt.Errorf("production is not JSON-shaped (expected an object, array, string or enum)")
+	}
+}
+
+func lowerRuleRef(g *Grammar, name string, visiting map[string]bool) (map[string]any, error) {
+	switch name {
+	case "string":
+		return map[string]any{"type": "string"}, nil
+	case "number":
+		return map[string]any{"type": "number"}, nil
+	case "integer":
+		return map[string]any{"type": "integer"}, nil
+	case "boolean":
+		return map[string]any{"type": "boolean"}, nil
+	}
+
+	if visiting[name] {
+		return nil, fmt.Errorf("rule %q is recursive and has no JSON Schema representation", name)
+	}
+	rule, ok := g.Rules[name]
+	if !ok {
+		return nil, fmt.Errorf("grammar references undefined rule %q", name)
+	}
+
+	visiting[name] = true
+	defer delete(visiting, name)
+	return lowerExpr(g, rule.Expr, visiting)
+}
+
+// lowerEnum lowers an alternation to a string enum; every branch must be a
+// string literal since Gemini's schema only supports string enums.
+func lowerEnum(branches Alternation) (map[string]any, error) {
+	values := make([]string, 0, len(branches))
+	for _, b := range branches {
+		lit, ok := b.(Literal)
+		if !ok {
+			return nil, fmt.Errorf("alternation has a non-literal branch; only enum-of-string productions lower to JSON Schema")
+		}
+		values = append(values, unquoteLiteral(string(lit)))
+	}
+	return map[string]any{"type": "string", "enum": values}, nil
+}
+
+// lowerSequence recognizes the two structural shapes a JSON-producing
+// sequence takes: an object wrapped in literal "{"/"}" braces, or an array
+// wrapped in literal "["/"]" brackets.
+func lowerSequence(g *Grammar, seq Sequence, visiting map[string]bool) (map[string]any, error) {
+	flat := stripWhitespace(flatten(seq))
+	if len(flat) < 2 {
+		return nil, fmt.Errorf("production is not JSON-shaped (expected an object, array, string or enum)")
+	}
+
+	first, firstOK := flat[0].(Literal)
+	last, lastOK := flat[len(flat)-1].(Literal)
+	if !firstOK || !lastOK {
+		return nil, fmt.Errorf("production is not JSON-shaped (expected an object, array, string or enum)")
+	}
+
+	switch {
+	case string(first) == "{" && string(last) == "}":
+		return lowerObject(g, flat[1:len(flat)-1], visiting)
+	case string(first) == "[" && string(last) == "]":
+		return lowerArray(g, flat[1:len(flat)-1], visiting)
+	default:
+		return nil, fmt.Errorf("production is not JSON-shaped (expected an object, array, string or enum)")
+	}
+}
+
+// lowerArray lowers a "[" ... "]" body: whatever single non-separator,
+// non-whitespace production remains describes the element type.
+func lowerArray(g *Grammar, body []Expr, visiting map[string]bool) (map[string]any, error) {
+	var item Expr
+	for _, e := range body {
+		if lit, ok := e.(Literal); ok && lit == "," {
+			continue
+		}
+		if item == nil {
+			item = e
+		}
+	}
+	if item == nil {
+		return map[string]any{"type": "array", "items": map[string]any{}}, nil
+	}
+	itemSchema, err := lowerExpr(g, item, visiting)
+	if err != nil {
+		return nil, fmt.Errorf("array items: %w", err)
+	}
+	return map[string]any{"type": "array", "items": itemSchema}, nil
+}
+
+// lowerObject lowers a "{" ... "}" body built from "key" ":" value members
+// separated by literal commas. Property names come from literal string
+// keys; a body that isn't shaped this way falls back to a bare object
+// schema rather than erroring, since "some object" is still JSON-shaped.
+func lowerObject(g *Grammar, body []Expr, visiting map[string]bool) (map[string]any, error) {
+	props := map[string]any{}
+	var required []string
+
+	i := 0
+	for i < len(body) {
+		lit, ok := body[i].(Literal)
+		if !ok {
+			return map[string]any{"type": "object"}, nil
+		}
+		if string(lit) == "," {
+			i++
+			continue
+		}
+		key := unquoteLiteral(string(lit))
+		if key == string(lit) {
+			// Didn't look like a "quoted" key literal; not a recognizable
+			// member list, so fall back to an untyped object.
+			return map[string]any{"type": "object"}, nil
+		}
+		i++
+		if i < len(body) {
+			if colon, ok := body[i].(Literal); ok && colon == ":" {
+				i++
+			}
+		}
+		if i >= len(body) {
+			return nil, fmt.Errorf("object member %q is missing a value production", key)
+		}
+
+		valueSchema, err := lowerExpr(g, body[i], visiting)
+		if err != nil {
+			return nil, fmt.Errorf("object member %q: %w", key, err)
+		}
+		props[key] = valueSchema
+		required = append(required, key)
+		i++
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}, nil
+}
+
+// flatten recursively inlines nested Sequence and Repeat nodes so a member list
+// written with a trailing "("," member")*" group reads as a flat token
+// stream.
+func flatten(elems []Expr) []Expr {
+	var out []Expr
+	for _, e := range elems {
+		switch v := e.(type) {
+		case Sequence:
+			out = append(out, flatten(v)...)
+		case Repeat:
+			out = append(out, flatten(asSequence(v.Inner))...)
+		default:
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func asSequence(e Expr) []Expr {
+	if seq, ok := e.(Sequence); ok {
+		return seq
+	}
+	return []Expr{e}
+}
+
+// stripWhitespace drops RuleRef elements conventionally used for
+// insignificant whitespace (e.g. "ws") so they don't interfere with
+// structural pattern matching.
+func stripWhitespace(elems []Expr) []Expr {
+	var out []Expr
+	for _, e := range elems {
+		if ref, ok := e.(RuleRef); ok && strings.HasPrefix(string(ref), "ws") {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// unquoteLiteral strips a leading/trailing '"' from a literal that encodes
+// a JSON string token, returning the literal unchanged if it isn't quoted.
+func unquoteLiteral(lit string) string {
+	if len(lit) >= 2 && strings.HasPrefix(lit, `"`) && strings.HasSuffix(lit, `"`) {
+		return lit[1 : len(lit)-1]
+	}
+	return lit
+}
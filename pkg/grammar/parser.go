@@ -0,0 +1,320 @@
+package grammar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// parser is a small recursive-descent parser over a single rule body.
+type parser struct {
+	src []rune
+	pos int
+}
+
+func (p *parser) atEnd() bool { return p.pos >= len(p.src) }
+
+func (p *parser) peek() rune {
+	if p.atEnd() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) skipWS() {
+	for !p.atEnd() && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// parseAlternation parses "sequence ('|' sequence)*".
+func (p *parser) parseAlternation() (Expr, error) {
+	first, err := p.parseSequence()
+	if err != nil {
+		return nil, err
+	}
+	branches := []Expr{first}
+
+	for {
+		p.skipWS()
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+		next, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, next)
+	}
+
+	if len(branches) == 1 {
+		return branches[0], nil
+	}
+	return Alternation(branches), nil
+}
+
+// parseSequence parses zero or more terms until '|', ')' or end of input.
+func (p *parser) parseSequence() (Expr, error) {
+	var elems []Expr
+	for {
+		p.skipWS()
+		if p.atEnd() || p.peek() == '|' || p.peek() == ')' {
+			break
+		}
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, term)
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("expected a production at position %d", p.pos)
+	}
+	if len(elems) == 1 {
+		return elems[0], nil
+	}
+	return Sequence(elems), nil
+}
+
+// parseTerm parses a single element followed by an optional "*", "+", "?"
+// or "{n}"/"{n,m}"/"{n,}" repetition suffix.
+func (p *parser) parseTerm() (Expr, error) {
+	elem, err := p.parseElement()
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.peek() {
+	case '*':
+		p.pos++
+		return Repeat{Inner: elem, Min: 0, Max: -1}, nil
+	case '+':
+		p.pos++
+		return Repeat{Inner: elem, Min: 1, Max: -1}, nil
+	case '?':
+		p.pos++
+		return Repeat{Inner: elem, Min: 0, Max: 1}, nil
+	case '{':
+		return p.parseBoundedRepeat(elem)
+	default:
+		return elem, nil
+	}
+}
+
+// parseBoundedRepeat parses a "{n}", "{n,m}" or "{n,}" repetition count
+// following elem, the way llama.cpp/LocalAI-style GBNF grammars do.
+func (p *parser) parseBoundedRepeat(elem Expr) (Expr, error) {
+	p.pos++ // '{'
+	min, err := p.parseRepeatCount()
+	if err != nil {
+		return nil, err
+	}
+
+	max := min
+	p.skipWS()
+	if p.peek() == ',' {
+		p.pos++
+		p.skipWS()
+		if p.peek() == '}' {
+			max = -1
+		} else {
+			max, err = p.parseRepeatCount()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	p.skipWS()
+	if p.peek() != '}' {
+		return nil, fmt.Errorf("expected '}' at position %d", p.pos)
+	}
+	p.pos++
+
+	return Repeat{Inner: elem, Min: min, Max: max}, nil
+}
+
+// parseRepeatCount parses the decimal integer bound inside a "{...}"
+// repetition.
+func (p *parser) parseRepeatCount() (int, error) {
+	start := p.pos
+	for !p.atEnd() && unicode.IsDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, fmt.Errorf("expected a repetition count at position %d", p.pos)
+	}
+	n, err := strconv.Atoi(string(p.src[start:p.pos]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid repetition count at position %d: %w", start, err)
+	}
+	return n, nil
+}
+
+// parseElement parses a string literal, grouped alternation, character
+// class or rule reference.
+func (p *parser) parseElement() (Expr, error) {
+	p.skipWS()
+	switch {
+	case p.atEnd():
+		return nil, fmt.Errorf("unexpected end of input")
+	case p.peek() == '"':
+		return p.parseLiteral()
+	case p.peek() == '(':
+		p.pos++
+		inner, err := p.parseAlternation()
+		if err != nil {
+			return nil, err
+		}
+		p.skipWS()
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
+		}
+		p.pos++
+		return inner, nil
+	case p.peek() == '[':
+		return p.parseCharClass()
+	case isIdentStart(p.peek()):
+		return p.parseRuleRef()
+	default:
+		return nil, fmt.Errorf("unexpected character %q at position %d", p.peek(), p.pos)
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isIdentCont(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+func (p *parser) parseRuleRef() (Expr, error) {
+	start := p.pos
+	for !p.atEnd() && isIdentCont(p.src[p.pos]) {
+		p.pos++
+	}
+	return RuleRef(string(p.src[start:p.pos])), nil
+}
+
+// parseLiteral parses a double-quoted string, unescaping \" \\ \n \t \r
+// \xHH \uXXXX as it goes.
+func (p *parser) parseLiteral() (Expr, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for {
+		if p.atEnd() {
+			return nil, fmt.Errorf("unterminated string literal")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c == '\\' && p.pos+1 < len(p.src) {
+			p.pos++
+			r, err := p.parseEscape()
+			if err != nil {
+				return nil, err
+			}
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteRune(c)
+		p.pos++
+	}
+	return Literal(b.String()), nil
+}
+
+// parseEscape parses the character(s) following a backslash at p.pos,
+// advancing past them, and returns the rune they encode. \xHH and \uXXXX
+// consume their hex digits here (rather than being silently mis-parsed as
+// the literal letter "x"/"u" followed by its digits) and report a parse
+// error if those digits are missing or invalid.
+func (p *parser) parseEscape() (rune, error) {
+	c := p.src[p.pos]
+	switch c {
+	case 'n':
+		p.pos++
+		return '\n', nil
+	case 't':
+		p.pos++
+		return '\t', nil
+	case 'r':
+		p.pos++
+		return '\r', nil
+	case 'x':
+		p.pos++
+		return p.parseHexEscape(2)
+	case 'u':
+		p.pos++
+		return p.parseHexEscape(4)
+	default:
+		p.pos++
+		return c, nil
+	}
+}
+
+// parseHexEscape reads exactly n hex digits starting at p.pos, the digits
+// of a \xHH or \uXXXX escape, and returns the rune they encode.
+func (p *parser) parseHexEscape(n int) (rune, error) {
+	if p.pos+n > len(p.src) {
+		return 0, fmt.Errorf("incomplete hex escape at position %d: want %d digits", p.pos, n)
+	}
+	digits := string(p.src[p.pos : p.pos+n])
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex escape %q at position %d", digits, p.pos)
+	}
+	p.pos += n
+	return rune(v), nil
+}
+
+// parseCharClass parses a "[...]" character class, supporting leading "^"
+// negation and "a-z" style ranges.
+func (p *parser) parseCharClass() (Expr, error) {
+	p.pos++ // '['
+	cc := CharClass{}
+	if p.peek() == '^' {
+		cc.Negated = true
+		p.pos++
+	}
+
+	for {
+		if p.atEnd() {
+			return nil, fmt.Errorf("unterminated character class")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			break
+		}
+
+		lo, err := p.readClassChar()
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if p.peek() == '-' && p.pos+1 < len(p.src) && p.src[p.pos+1] != ']' {
+			p.pos++
+			hi, err = p.readClassChar()
+			if err != nil {
+				return nil, err
+			}
+		}
+		cc.Ranges = append(cc.Ranges, CharRange{Lo: lo, Hi: hi})
+	}
+
+	return cc, nil
+}
+
+func (p *parser) readClassChar() (rune, error) {
+	c := p.src[p.pos]
+	if c == '\\' && p.pos+1 < len(p.src) {
+		p.pos++
+		return p.parseEscape()
+	}
+	p.pos++
+	return c, nil
+}
@@ -0,0 +1,144 @@
+package grammar
+
+import "fmt"
+
+// maxUnboundedRepeats caps "*"/"+" expansion so a pathological grammar (or
+// input) can't make Validate loop forever.
+const maxUnboundedRepeats = 10000
+
+// Validate reports whether output parses fully against g's root rule,
+// returning a descriptive error naming the rule that failed to match when
+// it doesn't.
+func Validate(g *Grammar, output string) error {
+	root, ok := g.Rules[g.Root]
+	if !ok {
+		return fmt.Errorf("grammar has no rule named %q", g.Root)
+	}
+
+	s := []rune(output)
+	ends := g.match(root.Expr, s, 0)
+	for _, end := range ends {
+		if end == len(s) {
+			return nil
+		}
+	}
+	return fmt.Errorf("output does not match grammar rule %q", g.Root)
+}
+
+// match returns every position in s reachable by matching expr starting at
+// pos. A CFG can be ambiguous, so this returns the full reachable set
+// rather than a single greedy match; callers only care whether len(s) is
+// among the results.
+func (g *Grammar) match(expr Expr, s []rune, pos int) []int {
+	switch e := expr.(type) {
+	case Literal:
+		lit := []rune(string(e))
+		if pos+len(lit) <= len(s) && string(s[pos:pos+len(lit)]) == string(lit) {
+			return []int{pos + len(lit)}
+		}
+		return nil
+
+	case RuleRef:
+		rule, ok := g.Rules[string(e)]
+		if !ok {
+			return nil
+		}
+		return g.match(rule.Expr, s, pos)
+
+	case CharClass:
+		if pos >= len(s) || !e.matches(s[pos]) {
+			return nil
+		}
+		return []int{pos + 1}
+
+	case Sequence:
+		positions := []int{pos}
+		for _, sub := range e {
+			positions = g.matchAll(sub, s, positions)
+			if len(positions) == 0 {
+				return nil
+			}
+		}
+		return positions
+
+	case Alternation:
+		seen := make(map[int]bool)
+		var out []int
+		for _, sub := range e {
+			for _, p := range g.match(sub, s, pos) {
+				if !seen[p] {
+					seen[p] = true
+					out = append(out, p)
+				}
+			}
+		}
+		return out
+
+	case Repeat:
+		return g.matchRepeat(e, s, pos)
+
+	default:
+		return nil
+	}
+}
+
+// matchAll applies match at every position in froms and unions the results.
+func (g *Grammar) matchAll(expr Expr, s []rune, froms []int) []int {
+	seen := make(map[int]bool)
+	var out []int
+	for _, p := range froms {
+		for _, np := range g.match(expr, s, p) {
+			if !seen[np] {
+				seen[np] = true
+				out = append(out, np)
+			}
+		}
+	}
+	return out
+}
+
+// matchRepeat expands Inner breadth-first up to Max repetitions (or
+// maxUnboundedRepeats when Max is -1), collecting every position reachable
+// once at least Min repetitions have been matched.
+func (g *Grammar) matchRepeat(r Repeat, s []rune, pos int) []int {
+	limit := r.Max
+	if limit < 0 {
+		limit = maxUnboundedRepeats
+	}
+
+	reached := make(map[int]bool)
+	if r.Min == 0 {
+		reached[pos] = true
+	}
+
+	// visited is keyed by position and by the repetition count capped at
+	// Min, so a position reached before Min repetitions can still be
+	// reached again once Min have been matched.
+	type state struct{ pos, level int }
+	frontier := []int{pos}
+	visited := map[state]bool{{pos, 0}: true}
+	for count := 1; count <= limit && len(frontier) > 0; count++ {
+		level := min(count, r.Min)
+		next := g.matchAll(r.Inner, s, frontier)
+		var advanced []int
+		for _, p := range next {
+			if visited[state{p, level}] {
+				continue // avoid looping forever on a zero-width match
+			}
+			visited[state{p, level}] = true
+			advanced = append(advanced, p)
+		}
+		frontier = advanced
+		if count >= r.Min {
+			for _, p := range frontier {
+				reached[p] = true
+			}
+		}
+	}
+
+	out := make([]int, 0, len(reached))
+	for p := range reached {
+		out = append(out, p)
+	}
+	return out
+}
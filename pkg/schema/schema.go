@@ -0,0 +1,491 @@
+// Package schema compiles JSON Schema (Draft 2020-12, as far as Gemini's
+// structured-output schema can represent it) into *genai.Schema values.
+//
+// It is a superset of the inline converter gemma-cli started with: it
+// resolves $ref/$defs (including recursive refs), flattens allOf, lowers
+// oneOf/anyOf to Gemini's nullable-union approximation, and folds
+// constraints that genai.Schema has no native field for (pattern, typed
+// enums, numeric/length bounds) into the compiled schema's Description so
+// the model still sees them.
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// CompileError reports a schema that failed to compile, naming the JSON
+// Pointer path of the offending node.
+type CompileError struct {
+	Path string
+	Err  error
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("schema error at %s: %v", e.Path, e.Err)
+}
+
+func (e *CompileError) Unwrap() error { return e.Err }
+
+// compiler holds the state shared across a single Compile call: the
+// document root (for $ref resolution) and a memo of refs already being
+// built, so recursive schemas terminate instead of looping forever.
+type compiler struct {
+	root map[string]any
+	memo map[string]*genai.Schema
+}
+
+// Compile converts a JSON Schema document into a *genai.Schema.
+func Compile(root map[string]any) (*genai.Schema, error) {
+	c := &compiler{root: root, memo: make(map[string]*genai.Schema)}
+	return c.compile(root, "#")
+}
+
+// compile builds a *genai.Schema for the node at path, resolving $ref,
+// allOf and oneOf/anyOf before falling through to the plain-schema case.
+func (c *compiler) compile(node map[string]any, path string) (*genai.Schema, error) {
+	out := &genai.Schema{}
+	if err := c.compileInto(out, node, path); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// compileInto is compile's dispatch logic, but writes into an
+// already-allocated *genai.Schema instead of returning a new one — so
+// compileRef can memoize the pointer for a $ref before the ref's target
+// (which may itself be $ref/allOf/oneOf/anyOf, not just a plain schema) is
+// resolved into it, letting recursive refs terminate correctly either way.
+func (c *compiler) compileInto(out *genai.Schema, node map[string]any, path string) error {
+	if ref, ok := node["$ref"].(string); ok {
+		resolved, err := c.compileRef(ref, path)
+		if err != nil {
+			return err
+		}
+		*out = *resolved
+		return nil
+	}
+
+	if subschemas, ok := asSchemaList(node["allOf"]); ok {
+		resolved, err := c.compileAllOf(node, subschemas, path)
+		if err != nil {
+			return err
+		}
+		*out = *resolved
+		return nil
+	}
+
+	if subschemas, ok := asSchemaList(node["oneOf"]); ok {
+		resolved, err := c.compileUnion(node, subschemas, path)
+		if err != nil {
+			return err
+		}
+		*out = *resolved
+		return nil
+	}
+	if subschemas, ok := asSchemaList(node["anyOf"]); ok {
+		resolved, err := c.compileUnion(node, subschemas, path)
+		if err != nil {
+			return err
+		}
+		*out = *resolved
+		return nil
+	}
+
+	return c.fill(out, node, path)
+}
+
+// compileRef resolves a $ref by JSON Pointer against the document root. The
+// target schema is memoized by pointer *before* it is populated, so a
+// recursive ref (directly or indirectly pointing back at itself) is answered
+// from the memo instead of recursing forever. compileInto copies the
+// memoized value into the referencing node, so a recursive reference gets
+// the target as it stands at that moment (still empty for a
+// self-reference), which cuts the recursion off at that depth. The target
+// is routed back through compileInto, not fill directly, since it may
+// itself be composed via allOf/oneOf/anyOf or another $ref rather than a
+// plain schema.
+func (c *compiler) compileRef(ref string, path string) (*genai.Schema, error) {
+	if cached, ok := c.memo[ref]; ok {
+		return cached, nil
+	}
+
+	target, err := resolvePointer(c.root, ref)
+	if err != nil {
+		return nil, &CompileError{Path: path, Err: err}
+	}
+
+	out := &genai.Schema{}
+	c.memo[ref] = out
+	if err := c.compileInto(out, target, ref); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// compileAllOf merges the node's own keywords with every allOf branch,
+// unioning properties/required. Later branches win on scalar fields such as
+// type/format so the most specific constraint (usually listed last)
+// applies.
+func (c *compiler) compileAllOf(node map[string]any, subschemas []map[string]any, path string) (*genai.Schema, error) {
+	merged, err := c.flattenAllOf(node, subschemas, path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &genai.Schema{}
+	if err := c.fill(out, merged, path); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// flattenAllOf merges node's own keywords with every allOf branch into a
+// single plain-schema map, unioning properties/required. Branches are
+// resolved via resolveInline, which follows $ref chains and recursively
+// flattens a branch that is itself allOf-composed, so a mixin pulled in
+// through $defs merges exactly as if it had been inlined.
+func (c *compiler) flattenAllOf(node map[string]any, subschemas []map[string]any, path string) (map[string]any, error) {
+	merged := map[string]any{}
+	for k, v := range node {
+		if k == "allOf" {
+			continue
+		}
+		merged[k] = v
+	}
+
+	mergedProps := map[string]any{}
+	var mergedRequired []any
+	for i, sub := range subschemas {
+		branchPath := fmt.Sprintf("%s/allOf/%d", path, i)
+		resolved, err := c.resolveInline(sub, branchPath)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range resolved {
+			if k == "properties" || k == "required" {
+				continue
+			}
+			merged[k] = v
+		}
+		if props, ok := resolved["properties"].(map[string]any); ok {
+			for name, propSchema := range props {
+				mergedProps[name] = propSchema
+			}
+		}
+		if req, ok := resolved["required"].([]any); ok {
+			mergedRequired = append(mergedRequired, req...)
+		}
+	}
+	if len(mergedProps) > 0 {
+		merged["properties"] = mergedProps
+	}
+	if len(mergedRequired) > 0 {
+		merged["required"] = mergedRequired
+	}
+	if merged["type"] == nil && len(mergedProps) > 0 {
+		merged["type"] = "object"
+	}
+	return merged, nil
+}
+
+// compileUnion lowers oneOf/anyOf to Gemini's nullable-union approximation:
+// a "null" branch turns into Nullable=true, and the first remaining branch
+// supplies the actual schema. This loses the distinction between
+// alternative non-null shapes, which Gemini's schema has no way to express.
+//
+// The chosen branch is compiled through compile, not merged as raw keys, so
+// a branch that is itself a $ref (e.g. a nullable reference to a $defs
+// object) resolves to its target instead of silently vanishing.
+func (c *compiler) compileUnion(node map[string]any, subschemas []map[string]any, path string) (*genai.Schema, error) {
+	nullable := false
+	var chosen map[string]any
+	for _, sub := range subschemas {
+		if isNullSchema(sub) {
+			nullable = true
+			continue
+		}
+		if chosen == nil {
+			chosen = sub
+		}
+	}
+
+	out := &genai.Schema{}
+	if chosen != nil {
+		compiled, err := c.compile(chosen, path)
+		if err != nil {
+			return nil, err
+		}
+		out = cloneSchema(compiled)
+	}
+
+	overrides := map[string]any{}
+	for k, v := range node {
+		switch k {
+		case "oneOf", "anyOf":
+			continue
+		default:
+			overrides[k] = v
+		}
+	}
+	if len(overrides) > 0 {
+		if err := c.fill(out, overrides, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if nullable {
+		out.Nullable = true
+	}
+	return out, nil
+}
+
+// cloneSchema shallow-copies a *genai.Schema so callers can layer
+// node-level overrides onto a branch compiled via compile without mutating
+// the compiled instance.
+func cloneSchema(s *genai.Schema) *genai.Schema {
+	clone := *s
+	return &clone
+}
+
+// resolveInline resolves a branch schema down to a plain map so callers can
+// merge its keywords structurally (allOf needs properties/required as data,
+// not a *genai.Schema). It follows $ref chains and recursively flattens a
+// resolved allOf, so an allOf branch that is a $ref into another allOf
+// mixin merges fully instead of leaving a stray "allOf" key that fill
+// would silently ignore.
+func (c *compiler) resolveInline(node map[string]any, path string) (map[string]any, error) {
+	if ref, ok := node["$ref"].(string); ok {
+		target, err := resolvePointer(c.root, ref)
+		if err != nil {
+			return nil, &CompileError{Path: path, Err: err}
+		}
+		return c.resolveInline(target, ref)
+	}
+	if subschemas, ok := asSchemaList(node["allOf"]); ok {
+		return c.flattenAllOf(node, subschemas, path)
+	}
+	return node, nil
+}
+
+// fill populates an already-allocated *genai.Schema from a plain-schema
+// node (no $ref/allOf/oneOf/anyOf at this level — those are handled by the
+// caller before fill is invoked).
+func (c *compiler) fill(out *genai.Schema, node map[string]any, path string) error {
+	var notes []string
+
+	switch t := node["type"].(type) {
+	case string:
+		gt, err := toGenaiType(t, path)
+		if err != nil {
+			return err
+		}
+		out.Type = gt
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		nonNull := ""
+		for _, s := range types {
+			if s == "null" {
+				out.Nullable = true
+				continue
+			}
+			if nonNull == "" {
+				nonNull = s
+			}
+		}
+		if nonNull != "" {
+			gt, err := toGenaiType(nonNull, path)
+			if err != nil {
+				return err
+			}
+			out.Type = gt
+		}
+	}
+
+	if desc, ok := node["description"].(string); ok {
+		out.Description = desc
+	}
+	if format, ok := node["format"].(string); ok {
+		out.Format = format
+	}
+	if nullable, ok := node["nullable"].(bool); ok && nullable {
+		out.Nullable = true
+	}
+
+	if props, ok := node["properties"].(map[string]any); ok {
+		out.Properties = make(map[string]*genai.Schema, len(props))
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propNode, ok := props[name].(map[string]any)
+			if !ok {
+				return &CompileError{Path: path + "/properties/" + name, Err: fmt.Errorf("property schema must be an object")}
+			}
+			propSchema, err := c.compile(propNode, path+"/properties/"+name)
+			if err != nil {
+				return err
+			}
+			out.Properties[name] = propSchema
+		}
+	}
+
+	if items, ok := node["items"].(map[string]any); ok {
+		itemSchema, err := c.compile(items, path+"/items")
+		if err != nil {
+			return err
+		}
+		out.Items = itemSchema
+	}
+
+	if required, ok := node["required"].([]string); ok {
+		out.Required = required
+	} else if required, ok := node["required"].([]any); ok {
+		out.Required = dedupeStrings(required)
+	}
+
+	if enum, ok := node["enum"].([]any); ok {
+		var strValues []string
+		var otherNotes []string
+		for _, v := range enum {
+			switch e := v.(type) {
+			case string:
+				strValues = append(strValues, e)
+			default:
+				otherNotes = append(otherNotes, fmt.Sprint(e))
+			}
+		}
+		if len(strValues) > 0 {
+			out.Enum = strValues
+		}
+		if len(otherNotes) > 0 {
+			notes = append(notes, "allowed values: "+strings.Join(otherNotes, ", "))
+		}
+	}
+
+	// Gemini's schema has no native field for these JSON Schema keywords;
+	// fold them into the description so the model still sees the
+	// constraint, the same way typed enum values are handled above.
+	for _, keyword := range []string{
+		"pattern", "minLength", "maxLength", "minimum", "maximum", "minItems", "maxItems",
+	} {
+		if v, ok := node[keyword]; ok {
+			notes = append(notes, fmt.Sprintf("%s: %v", keyword, v))
+		}
+	}
+
+	if len(notes) > 0 {
+		if out.Description != "" {
+			out.Description += " (" + strings.Join(notes, "; ") + ")"
+		} else {
+			out.Description = strings.Join(notes, "; ")
+		}
+	}
+
+	return nil
+}
+
+// toGenaiType maps a JSON Schema primitive type name to genai.Type.
+func toGenaiType(t string, path string) (genai.Type, error) {
+	switch t {
+	case "object":
+		return genai.TypeObject, nil
+	case "array":
+		return genai.TypeArray, nil
+	case "string":
+		return genai.TypeString, nil
+	case "number":
+		return genai.TypeNumber, nil
+	case "integer":
+		return genai.TypeInteger, nil
+	case "boolean":
+		return genai.TypeBoolean, nil
+	case "null":
+		return genai.TypeUnspecified, nil
+	default:
+		return genai.TypeUnspecified, &CompileError{Path: path, Err: fmt.Errorf("unsupported type: %s", t)}
+	}
+}
+
+// asSchemaList type-asserts a raw "allOf"/"oneOf"/"anyOf" value into a slice
+// of schema maps.
+func asSchemaList(raw any) ([]map[string]any, bool) {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, false
+	}
+	out := make([]map[string]any, 0, len(list))
+	for _, v := range list {
+		if m, ok := v.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out, true
+}
+
+// isNullSchema reports whether a branch schema is exactly {"type": "null"}.
+func isNullSchema(node map[string]any) bool {
+	t, ok := node["type"].(string)
+	return ok && t == "null" && len(node) == 1
+}
+
+// dedupeStrings converts a []any of strings into a deduplicated []string,
+// preserving first-seen order.
+func dedupeStrings(raw []any) []string {
+	seen := make(map[string]bool, len(raw))
+	out := make([]string, 0, len(raw))
+	for _, v := range raw {
+		s, ok := v.(string)
+		if !ok || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	return out
+}
+
+// resolvePointer resolves a JSON Pointer $ref (e.g. "#/$defs/Address")
+// against root, per RFC 6901.
+func resolvePointer(root map[string]any, ref string) (map[string]any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only local $ref pointers are supported, got %q", ref)
+	}
+	ref = strings.TrimPrefix(ref, "#")
+	ref = strings.TrimPrefix(ref, "/")
+	if ref == "" {
+		return root, nil
+	}
+
+	var cur any = root
+	for _, tok := range strings.Split(ref, "/") {
+		tok = strings.ReplaceAll(tok, "~1", "/")
+		tok = strings.ReplaceAll(tok, "~0", "~")
+
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot resolve $ref segment %q: not an object", tok)
+		}
+		next, ok := m[tok]
+		if !ok {
+			return nil, fmt.Errorf("$ref segment %q not found", tok)
+		}
+		cur = next
+	}
+
+	resolved, ok := cur.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("$ref %q does not point at an object", ref)
+	}
+	return resolved, nil
+}
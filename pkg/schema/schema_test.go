@@ -0,0 +1,350 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   map[string]any
+		check   func(t *testing.T, out *genai.Schema)
+		wantErr bool
+	}{
+		{
+			name: "basic object with properties and required",
+			input: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{"type": "string"},
+					"age":  map[string]any{"type": "integer"},
+				},
+				"required": []any{"name"},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Type != genai.TypeObject {
+					t.Fatalf("Type = %v, want object", out.Type)
+				}
+				if out.Properties["name"].Type != genai.TypeString {
+					t.Fatalf("name.Type = %v, want string", out.Properties["name"].Type)
+				}
+				if len(out.Required) != 1 || out.Required[0] != "name" {
+					t.Fatalf("Required = %v, want [name]", out.Required)
+				}
+			},
+		},
+		{
+			name: "array items",
+			input: map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Type != genai.TypeArray {
+					t.Fatalf("Type = %v, want array", out.Type)
+				}
+				if out.Items == nil || out.Items.Type != genai.TypeString {
+					t.Fatalf("Items = %+v, want string schema", out.Items)
+				}
+			},
+		},
+		{
+			name: "string enum",
+			input: map[string]any{
+				"type": "string",
+				"enum": []any{"red", "green", "blue"},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if len(out.Enum) != 3 {
+					t.Fatalf("Enum = %v, want 3 values", out.Enum)
+				}
+			},
+		},
+		{
+			name: "typed enum folds into description",
+			input: map[string]any{
+				"type": "integer",
+				"enum": []any{1, 2, 3},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if !strings.Contains(out.Description, "1") || !strings.Contains(out.Description, "allowed values") {
+					t.Fatalf("Description = %q, want it to mention allowed values", out.Description)
+				}
+			},
+		},
+		{
+			name: "type array with null marks nullable",
+			input: map[string]any{
+				"type": []any{"string", "null"},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Type != genai.TypeString || !out.Nullable {
+					t.Fatalf("got Type=%v Nullable=%v, want string/nullable", out.Type, out.Nullable)
+				}
+			},
+		},
+		{
+			name: "$ref resolves against $defs",
+			input: map[string]any{
+				"$defs": map[string]any{
+					"Address": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"city": map[string]any{"type": "string"},
+						},
+					},
+				},
+				"type": "object",
+				"properties": map[string]any{
+					"home": map[string]any{"$ref": "#/$defs/Address"},
+				},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				home := out.Properties["home"]
+				if home == nil || home.Type != genai.TypeObject {
+					t.Fatalf("home = %+v, want resolved object schema", home)
+				}
+				if home.Properties["city"].Type != genai.TypeString {
+					t.Fatalf("home.city.Type = %v, want string", home.Properties["city"].Type)
+				}
+			},
+		},
+		{
+			name: "$ref resolves through an allOf-composed $defs entry",
+			input: map[string]any{
+				"$defs": map[string]any{
+					"Address": map[string]any{
+						"allOf": []any{
+							map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"street": map[string]any{"type": "string"},
+								},
+								"required": []any{"street"},
+							},
+							map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"city": map[string]any{"type": "string"},
+								},
+								"required": []any{"city"},
+							},
+						},
+					},
+				},
+				"type": "object",
+				"properties": map[string]any{
+					"home": map[string]any{"$ref": "#/$defs/Address"},
+				},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				home := out.Properties["home"]
+				if home == nil || home.Type != genai.TypeObject {
+					t.Fatalf("home = %+v, want resolved object schema", home)
+				}
+				if home.Properties["street"] == nil || home.Properties["city"] == nil {
+					t.Fatalf("home.Properties = %v, want street and city", home.Properties)
+				}
+				if len(home.Required) != 2 {
+					t.Fatalf("home.Required = %v, want 2 entries", home.Required)
+				}
+			},
+		},
+		{
+			name: "$ref resolves through a oneOf-composed $defs entry",
+			input: map[string]any{
+				"$defs": map[string]any{
+					"StringOrNull": map[string]any{
+						"oneOf": []any{
+							map[string]any{"type": "string"},
+							map[string]any{"type": "null"},
+						},
+					},
+				},
+				"type": "object",
+				"properties": map[string]any{
+					"nickname": map[string]any{"$ref": "#/$defs/StringOrNull"},
+				},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				nickname := out.Properties["nickname"]
+				if nickname == nil || nickname.Type != genai.TypeString || !nickname.Nullable {
+					t.Fatalf("nickname = %+v, want string/nullable", nickname)
+				}
+			},
+		},
+		{
+			name: "recursive $ref terminates",
+			input: map[string]any{
+				"$defs": map[string]any{
+					"Node": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"next": map[string]any{"$ref": "#/$defs/Node"},
+						},
+					},
+				},
+				"$ref": "#/$defs/Node",
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Type != genai.TypeObject {
+					t.Fatalf("Type = %v, want object", out.Type)
+				}
+				next := out.Properties["next"]
+				if next == nil {
+					t.Fatalf("expected recursive 'next' property to resolve")
+				}
+			},
+		},
+		{
+			name: "allOf merges properties and required",
+			input: map[string]any{
+				"allOf": []any{
+					map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"id": map[string]any{"type": "string"},
+						},
+						"required": []any{"id"},
+					},
+					map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"name": map[string]any{"type": "string"},
+						},
+						"required": []any{"name"},
+					},
+				},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Properties["id"] == nil || out.Properties["name"] == nil {
+					t.Fatalf("Properties = %v, want id and name", out.Properties)
+				}
+				if len(out.Required) != 2 {
+					t.Fatalf("Required = %v, want 2 entries", out.Required)
+				}
+			},
+		},
+		{
+			name: "oneOf with null branch lowers to nullable",
+			input: map[string]any{
+				"oneOf": []any{
+					map[string]any{"type": "string"},
+					map[string]any{"type": "null"},
+				},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Type != genai.TypeString || !out.Nullable {
+					t.Fatalf("got Type=%v Nullable=%v, want string/nullable", out.Type, out.Nullable)
+				}
+			},
+		},
+		{
+			name: "oneOf with $ref branch resolves the reference",
+			input: map[string]any{
+				"$defs": map[string]any{
+					"Address": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"city": map[string]any{"type": "string"},
+						},
+					},
+				},
+				"oneOf": []any{
+					map[string]any{"$ref": "#/$defs/Address"},
+					map[string]any{"type": "null"},
+				},
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if out.Type != genai.TypeObject || !out.Nullable {
+					t.Fatalf("got Type=%v Nullable=%v, want object/nullable", out.Type, out.Nullable)
+				}
+				if out.Properties["city"].Type != genai.TypeString {
+					t.Fatalf("Properties = %v, want city:string", out.Properties)
+				}
+			},
+		},
+		{
+			name: "nullable keyword",
+			input: map[string]any{
+				"type":     "string",
+				"nullable": true,
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				if !out.Nullable {
+					t.Fatalf("Nullable = false, want true")
+				}
+			},
+		},
+		{
+			name: "numeric and length constraints fold into description",
+			input: map[string]any{
+				"type":      "string",
+				"minLength": 2,
+				"maxLength": 10,
+				"pattern":   "^[a-z]+$",
+			},
+			check: func(t *testing.T, out *genai.Schema) {
+				for _, want := range []string{"minLength", "maxLength", "pattern"} {
+					if !strings.Contains(out.Description, want) {
+						t.Fatalf("Description = %q, want it to mention %s", out.Description, want)
+					}
+				}
+			},
+		},
+		{
+			name: "unsupported type errors with JSON Pointer path",
+			input: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"bad": map[string]any{"type": "not-a-type"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Compile(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Compile() error = nil, want error")
+				}
+				var compileErr *CompileError
+				if !asCompileError(err, &compileErr) {
+					t.Fatalf("Compile() error = %v, want *CompileError", err)
+				}
+				if compileErr.Path == "" {
+					t.Fatalf("CompileError.Path is empty, want a JSON Pointer path")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compile() unexpected error: %v", err)
+			}
+			tt.check(t, out)
+		})
+	}
+}
+
+// asCompileError unwraps err looking for a *CompileError, the way
+// errors.As would, without requiring the caller to import errors just for
+// this one assertion.
+func asCompileError(err error, target **CompileError) bool {
+	for err != nil {
+		if ce, ok := err.(*CompileError); ok {
+			*target = ce
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
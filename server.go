@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/egorsmkv/gemma-cli/pkg/schema"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// ModelAliases maps OpenAI-style model names to the Gemini model ID actually
+// used to serve them, so existing OpenAI clients can point at this binary
+// without knowing about Gemini model IDs.
+var ModelAliases = map[string]string{
+	"gpt-3.5-turbo":          "gemini-1.5-flash",
+	"gpt-4":                  "gemini-1.5-pro",
+	"gpt-4o":                 "gemini-1.5-pro",
+	"gpt-4o-mini":            "gemini-1.5-flash",
+	"text-embedding-ada-002": "text-embedding-004",
+}
+
+// resolveModel maps an OpenAI-style model name to a Gemini model ID via
+// ModelAliases, passing it through unchanged if there is no alias.
+func resolveModel(name string) string {
+	if alias, ok := ModelAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// Server holds the shared state for the OpenAI-compatible HTTP server.
+type Server struct {
+	client *genai.Client
+
+	// defaultModel is used for any request that omits "model", from the
+	// -model flag given at -serve startup.
+	defaultModel string
+}
+
+// runServer starts the OpenAI-compatible HTTP server configured by
+// config.ServeAddr and blocks until it exits. config.Model (from -model) is
+// used as the default model for any request that omits "model".
+func runServer(config Config) error {
+	ctx := context.Background()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.APIKey))
+	if err != nil {
+		return fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+	defer client.Close()
+
+	srv := &Server{client: client, defaultModel: config.Model}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", srv.handleChatCompletions)
+	mux.HandleFunc("/v1/completions", srv.handleCompletions)
+	mux.HandleFunc("/v1/embeddings", srv.handleEmbeddings)
+
+	slog.Info("Starting OpenAI-compatible server", "addr", config.ServeAddr)
+	return http.ListenAndServe(config.ServeAddr, mux)
+}
+
+// ChatMessage is a single OpenAI chat message.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ResponseFormat mirrors OpenAI's response_format request field, including
+// the json_schema variant used to request schema-constrained output.
+type ResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *JSONSchemaSpec `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaSpec is the nested schema payload of a json_schema response
+// format.
+type JSONSchemaSpec struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+// ChatCompletionRequest mirrors OpenAI's POST /v1/chat/completions body.
+type ChatCompletionRequest struct {
+	Model          string          `json:"model"`
+	Messages       []ChatMessage   `json:"messages"`
+	Stream         bool            `json:"stream"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ChatCompletionResponse mirrors OpenAI's chat completion response.
+type ChatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+}
+
+// ChatCompletionChoice is a single completion choice.
+type ChatCompletionChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message,omitempty"`
+	Delta        ChatMessage `json:"delta,omitempty"`
+	FinishReason string      `json:"finish_reason,omitempty"`
+}
+
+// handleChatCompletions implements POST /v1/chat/completions, translating
+// the OpenAI request shape into a genai.GenerativeModel call and mapping the
+// response (or streamed chunks) back to the OpenAI wire format.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Messages) == 0 {
+		http.Error(w, "messages must not be empty", http.StatusBadRequest)
+		return
+	}
+	if req.Model == "" {
+		req.Model = s.defaultModel
+	}
+
+	ctx := r.Context()
+	model := s.client.GenerativeModel(resolveModel(req.Model))
+
+	if req.ResponseFormat != nil && req.ResponseFormat.Type == "json_schema" && req.ResponseFormat.JSONSchema != nil {
+		genaiSchema, err := schema.Compile(req.ResponseFormat.JSONSchema.Schema)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid response_format schema: %v", err), http.StatusBadRequest)
+			return
+		}
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = genaiSchema
+	}
+
+	chat := model.StartChat()
+	chat.History = historyFromMessages(req.Messages[:len(req.Messages)-1])
+	prompt := genai.Text(req.Messages[len(req.Messages)-1].Content)
+
+	created := time.Now().Unix()
+	id := fmt.Sprintf("chatcmpl-%d", created)
+
+	if req.Stream {
+		s.streamChatCompletion(w, ctx, chat, prompt, id, req.Model, created)
+		return
+	}
+
+	resp, err := chat.SendMessage(ctx, prompt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("generation failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   req.Model,
+		Choices: []ChatCompletionChoice{{
+			Index:        0,
+			Message:      ChatMessage{Role: "assistant", Content: responseText(resp)},
+			FinishReason: "stop",
+		}},
+	})
+}
+
+// streamChatCompletion sends prompt on chat and writes the streamed reply as
+// a series of OpenAI-style SSE "data:" chunks, ending with "data: [DONE]".
+func (s *Server) streamChatCompletion(w http.ResponseWriter, ctx context.Context, chat *genai.ChatSession, prompt genai.Part, id, model string, created int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	iter := chat.SendMessageStream(ctx, prompt)
+	for {
+		resp, err := iter.Next()
+		if err != nil {
+			break
+		}
+
+		chunk := ChatCompletionResponse{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []ChatCompletionChoice{{
+				Index: 0,
+				Delta: ChatMessage{Content: responseText(resp)},
+			}},
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// CompletionRequest mirrors OpenAI's legacy POST /v1/completions body.
+type CompletionRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream"`
+}
+
+// CompletionResponse mirrors OpenAI's legacy completion response.
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+}
+
+// CompletionChoice is a single legacy completion choice.
+type CompletionChoice struct {
+	Index        int    `json:"index"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// handleCompletions implements POST /v1/completions for clients still on
+// the legacy text-completion API.
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	var req CompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Model == "" {
+		req.Model = s.defaultModel
+	}
+
+	ctx := r.Context()
+	model := s.client.GenerativeModel(resolveModel(req.Model))
+	resp, err := model.GenerateContent(ctx, genai.Text(req.Prompt))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("generation failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CompletionResponse{
+		ID:      fmt.Sprintf("cmpl-%d", time.Now().Unix()),
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []CompletionChoice{{
+			Index:        0,
+			Text:         responseText(resp),
+			FinishReason: "stop",
+		}},
+	})
+}
+
+// EmbeddingRequest mirrors OpenAI's POST /v1/embeddings body.
+type EmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// EmbeddingResponse mirrors OpenAI's embeddings response.
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+}
+
+// EmbeddingData is a single embedding result.
+type EmbeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// handleEmbeddings implements POST /v1/embeddings.
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Model == "" {
+		req.Model = s.defaultModel
+	}
+
+	ctx := r.Context()
+	em := s.client.EmbeddingModel(resolveModel(req.Model))
+	resp, err := em.EmbedContent(ctx, genai.Text(req.Input))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("embedding failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(EmbeddingResponse{
+		Object: "list",
+		Model:  req.Model,
+		Data: []EmbeddingData{{
+			Object:    "embedding",
+			Index:     0,
+			Embedding: resp.Embedding.Values,
+		}},
+	})
+}
+
+// responseText concatenates the text parts of a GenerateContentResponse's
+// first candidate.
+func responseText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if txt, ok := part.(genai.Text); ok {
+			text.WriteString(string(txt))
+		}
+	}
+	return text.String()
+}
+
+// historyFromMessages converts every given OpenAI chat message into genai
+// chat history; the caller passes all but the final message, which it sends
+// as the prompt.
+func historyFromMessages(messages []ChatMessage) []*genai.Content {
+	history := make([]*genai.Content, 0, len(messages))
+	for _, msg := range messages {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		history = append(history, &genai.Content{
+			Role:  role,
+			Parts: []genai.Part{genai.Text(msg.Content)},
+		})
+	}
+	return history
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestResolveModel(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "gpt-3.5-turbo", want: "gemini-1.5-flash"},
+		{name: "gpt-4", want: "gemini-1.5-pro"},
+		{name: "gpt-4o-mini", want: "gemini-1.5-flash"},
+		{name: "gemini-1.5-pro", want: "gemini-1.5-pro"}, // unaliased names pass through
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveModel(tt.name); got != tt.want {
+				t.Fatalf("resolveModel(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}